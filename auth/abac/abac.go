@@ -0,0 +1,362 @@
+// Package abac adalah evaluator attribute-based access control untuk keputusan tingkat resource,
+// misalnya "user boleh mengubah invoice milik timnya sendiri di bawah 10 juta".
+//
+// Policy mengevaluasi atribut subjek (dari token), atribut resource (diberikan handler), dan
+// atribut lingkungan (waktu, IP). Evaluasi memakai deny-overrides: satu policy Deny yang cocok
+// menolak akses, dan tanpa policy Allow yang cocok akses juga ditolak. Setiap keputusan dicatat
+// dengan zerolog untuk audit. RBAC (auth.RBACMiddleware) tetap dipakai untuk izin kasar per route;
+// ABAC melengkapinya di dalam handler setelah resource dimuat.
+package abac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoSubject dikembalikan jika context tidak membawa identitas terotentikasi.
+var ErrNoSubject = errors.New("abac: no authenticated subject in context")
+
+// Subject adalah atribut pemanggil.
+type Subject struct {
+	UserID      string
+	TenantID    string
+	Roles       []string
+	Permissions []string
+	Attributes  map[string]interface{}
+}
+
+// HasRole memeriksa apakah subjek memiliki role.
+func (s *Subject) HasRole(role string) bool {
+	for _, r := range s.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource adalah atribut objek yang diakses, diisi oleh handler setelah memuat data.
+type Resource struct {
+	Type string
+	ID   string
+	// TenantID wajib diisi dan harus sama dengan tenant subjek, kecuali Type terdaftar di
+	// WithGlobalResourceTypes. Resource tanpa tenant ditolak agar ResourceLoader yang lupa
+	// mengisinya tidak membuka akses lintas tenant.
+	TenantID   string
+	OwnerID    string
+	Attributes map[string]interface{}
+}
+
+// Environment adalah atribut konteks request.
+type Environment struct {
+	Time       time.Time
+	IP         string
+	Attributes map[string]interface{}
+}
+
+// Request adalah satu pertanyaan otorisasi: bolehkah Subject melakukan Action pada Resource.
+type Request struct {
+	Subject     Subject
+	Action      string
+	Resource    Resource
+	Environment Environment
+}
+
+// Condition adalah predikat atas Request. Lihat conditions.go untuk kondisi siap pakai.
+type Condition func(r *Request) bool
+
+// Effect adalah hasil policy yang cocok.
+type Effect int
+
+const (
+	Allow Effect = iota
+	Deny
+)
+
+func (e Effect) String() string {
+	if e == Deny {
+		return "deny"
+	}
+	return "allow"
+}
+
+// Policy adalah satu aturan ABAC.
+type Policy struct {
+	ID          string
+	Description string
+	Effect      Effect
+	// Actions yang dicakup. "*" mencakup semua aksi; "invoice:*" mencakup semua aksi berawalan "invoice:".
+	Actions []string
+	// ResourceTypes yang dicakup; kosong berarti semua tipe.
+	ResourceTypes []string
+	// Condition opsional; nil berarti policy selalu cocok untuk aksi dan tipe resource di atas.
+	Condition Condition
+}
+
+func (p *Policy) applies(r *Request) bool {
+	if !matchAny(p.Actions, r.Action) {
+		return false
+	}
+	if len(p.ResourceTypes) > 0 && !contains(p.ResourceTypes, r.Resource.Type) {
+		return false
+	}
+	return p.Condition == nil || p.Condition(r)
+}
+
+// Decision adalah hasil evaluasi beserta alasan untuk log audit.
+type Decision struct {
+	Allowed  bool
+	PolicyID string // Policy yang menentukan hasil; kosong jika default deny.
+	Reason   string
+}
+
+// DeniedError dikembalikan Authorize jika akses ditolak.
+type DeniedError struct {
+	Decision Decision
+}
+
+func (e *DeniedError) Error() string {
+	return "access denied: " + e.Decision.Reason
+}
+
+// SubjectEnricher menambahkan atribut subjek yang tidak ada di token, misalnya dari database.
+type SubjectEnricher func(ctx context.Context, subject *Subject) error
+
+// Option mengonfigurasi Engine.
+type Option func(*Engine)
+
+// WithLogger mengganti logger keputusan. Default memakai logger global zerolog.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(e *Engine) { e.logger = &logger }
+}
+
+// WithGlobalResourceTypes menandai tipe resource yang tidak dimiliki tenant mana pun (misalnya
+// katalog mata uang bersama), sehingga boleh memiliki TenantID kosong.
+func WithGlobalResourceTypes(types ...string) Option {
+	return func(e *Engine) {
+		if e.globalTypes == nil {
+			e.globalTypes = make(map[string]struct{}, len(types))
+		}
+		for _, t := range types {
+			e.globalTypes[t] = struct{}{}
+		}
+	}
+}
+
+// WithSubjectEnricher memasang SubjectEnricher.
+func WithSubjectEnricher(enricher SubjectEnricher) Option {
+	return func(e *Engine) { e.enricher = enricher }
+}
+
+// Engine mengevaluasi sekumpulan Policy. Aman dipakai bersamaan oleh banyak goroutine.
+type Engine struct {
+	policies []Policy
+	logger   *zerolog.Logger
+	enricher SubjectEnricher
+	now      func() time.Time
+	// globalTypes adalah tipe resource yang boleh tanpa TenantID.
+	globalTypes map[string]struct{}
+}
+
+// NewEngine membuat Engine dari policies.
+func NewEngine(policies []Policy, opts ...Option) *Engine {
+	e := &Engine{policies: policies, now: time.Now}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+type environmentContextKey struct{}
+
+// WithEnvironment menyimpan atribut lingkungan di context, misalnya IP klien di handler gRPC.
+// Untuk *gin.Context, IP diambil otomatis dari c.ClientIP().
+func WithEnvironment(ctx context.Context, env Environment) context.Context {
+	return context.WithValue(ctx, environmentContextKey{}, env)
+}
+
+// Authorize memutuskan apakah subjek di ctx boleh melakukan action pada resource.
+// Mengembalikan nil jika diizinkan, *DeniedError jika ditolak, atau error lain jika
+// subjek tidak bisa ditentukan.
+func (e *Engine) Authorize(ctx context.Context, action string, resource Resource) error {
+	decision, err := e.Decide(ctx, action, resource)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return &DeniedError{Decision: decision}
+	}
+	return nil
+}
+
+// Decide seperti Authorize tetapi mengembalikan Decision lengkap.
+func (e *Engine) Decide(ctx context.Context, action string, resource Resource) (Decision, error) {
+	claims, err := auth.ClaimsFromContext(ctx)
+	if err != nil {
+		return Decision{}, fmt.Errorf("%w: %v", ErrNoSubject, err)
+	}
+	req := &Request{
+		Subject: Subject{
+			UserID:      claims.Subject,
+			TenantID:    claims.TenantID,
+			Roles:       claims.AllRoles(),
+			Permissions: claims.Permissions,
+			Attributes:  claims.Attributes,
+		},
+		Action:      action,
+		Resource:    resource,
+		Environment: e.environment(ctx),
+	}
+	if e.enricher != nil {
+		if err := e.enricher(ctx, &req.Subject); err != nil {
+			return Decision{}, fmt.Errorf("abac: failed to enrich subject: %w", err)
+		}
+	}
+
+	decision := e.Evaluate(req)
+	e.logDecision(req, decision)
+	return decision, nil
+}
+
+func (e *Engine) environment(ctx context.Context) Environment {
+	env, _ := ctx.Value(environmentContextKey{}).(Environment)
+	if ginCtx, ok := ctx.(*gin.Context); ok && ginCtx.Request != nil {
+		if stored, ok := ginCtx.Request.Context().Value(environmentContextKey{}).(Environment); ok {
+			env = stored
+		}
+		if env.IP == "" {
+			env.IP = ginCtx.ClientIP()
+		}
+	}
+	if env.Time.IsZero() {
+		env.Time = e.now()
+	}
+	return env
+}
+
+// Evaluate menerapkan policies ke Request tanpa logging. Berguna untuk pengujian policy.
+func (e *Engine) Evaluate(req *Request) Decision {
+	// Isolasi tenant selalu berlaku, apa pun isi policy, dan gagal tertutup jika tenant resource kosong.
+	if req.Resource.TenantID == "" {
+		if _, global := e.globalTypes[req.Resource.Type]; !global {
+			return Decision{Allowed: false, Reason: "resource has no tenant"}
+		}
+	} else if req.Resource.TenantID != req.Subject.TenantID {
+		return Decision{Allowed: false, Reason: "resource belongs to another tenant"}
+	}
+
+	var allowedBy *Policy
+	for i := range e.policies {
+		p := &e.policies[i]
+		if !p.applies(req) {
+			continue
+		}
+		if p.Effect == Deny {
+			return Decision{Allowed: false, PolicyID: p.ID, Reason: "denied by policy " + p.ID}
+		}
+		if allowedBy == nil {
+			allowedBy = p
+		}
+	}
+	if allowedBy != nil {
+		return Decision{Allowed: true, PolicyID: allowedBy.ID, Reason: "allowed by policy " + allowedBy.ID}
+	}
+	return Decision{Allowed: false, Reason: "no policy allows this action"}
+}
+
+func (e *Engine) logDecision(req *Request, decision Decision) {
+	logger := e.logger
+	if logger == nil {
+		logger = &log.Logger
+	}
+	event := logger.Info()
+	if !decision.Allowed {
+		event = logger.Warn()
+	}
+	event.
+		Str("component", "abac").
+		Bool("allowed", decision.Allowed).
+		Str("policy_id", decision.PolicyID).
+		Str("reason", decision.Reason).
+		Str("action", req.Action).
+		Str("resource_type", req.Resource.Type).
+		Str("resource_id", req.Resource.ID).
+		Str("tenant_id", req.Subject.TenantID).
+		Str("user_id", req.Subject.UserID).
+		Str("ip", req.Environment.IP).
+		Msg("ABAC decision")
+}
+
+// ResourceLoader memuat resource untuk request gin. Kembalikan *auth.AuthError untuk
+// mengatur respons (misal 404); error lain dijawab 500.
+type ResourceLoader func(c *gin.Context) (Resource, error)
+
+// Require membuat middleware yang memuat resource lalu memanggil Authorize.
+// Harus dipasang setelah JWTMiddleware (atau auth.Chain).
+func (e *Engine) Require(action string, load ResourceLoader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource, err := load(c)
+		if err != nil {
+			var authErr *auth.AuthError
+			if !errors.As(err, &authErr) {
+				authErr = &auth.AuthError{Status: http.StatusInternalServerError, Message: "Could not load resource", Err: err}
+			}
+			auth.DefaultErrorResponder(c, authErr)
+			c.Abort()
+			return
+		}
+		if !e.Check(c, action, resource) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Check dipakai di dalam handler yang sudah memuat resource sendiri. Jika akses ditolak,
+// respons 403 ditulis, request di-abort, dan false dikembalikan.
+func (e *Engine) Check(c *gin.Context, action string, resource Resource) bool {
+	err := e.Authorize(c, action, resource)
+	if err == nil {
+		return true
+	}
+
+	authErr := &auth.AuthError{Status: http.StatusForbidden, Message: "Access denied"}
+	if errors.Is(err, ErrNoSubject) {
+		authErr.Message = "Claims not found in context"
+	} else if !errors.As(err, new(*DeniedError)) {
+		authErr = &auth.AuthError{Status: http.StatusInternalServerError, Message: "Could not evaluate access policy", Err: err}
+	}
+	auth.DefaultErrorResponder(c, authErr)
+	c.Abort()
+	return false
+}
+
+func matchAny(patterns []string, action string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == action {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(items []string, item string) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
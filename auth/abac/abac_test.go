@@ -0,0 +1,175 @@
+package abac
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func testPolicies() []Policy {
+	return []Policy{
+		{ID: "team-read", Effect: Allow, Actions: []string{"invoice:read"}, ResourceTypes: []string{"invoice"}, Condition: AttributesEqual("team_id", "team_id")},
+		{ID: "owner-update", Effect: Allow, Actions: []string{"invoice:update"}, Condition: All(SubjectIsOwner(), ResourceAttributeBelow("amount", 10_000_000))},
+		{ID: "locked", Effect: Deny, Actions: []string{"invoice:*"}, Condition: func(r *Request) bool { return r.Resource.Attributes["locked"] == true }},
+		{ID: "admin", Effect: Allow, Actions: []string{"*"}, Condition: SubjectHasRole("admin")},
+		{ID: "currency-read", Effect: Allow, Actions: []string{"currency:read"}, ResourceTypes: []string{"currency"}},
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	e := NewEngine(testPolicies(), WithGlobalResourceTypes("currency"))
+	staff := Subject{UserID: "user-1", TenantID: "tenant-a", Roles: []string{"staff"}, Attributes: map[string]interface{}{"team_id": float64(7)}}
+	admin := Subject{UserID: "admin-1", TenantID: "tenant-a", Roles: []string{"admin"}}
+	invoice := func(mutate func(*Resource)) Resource {
+		r := Resource{Type: "invoice", ID: "inv-1", TenantID: "tenant-a", OwnerID: "user-1", Attributes: map[string]interface{}{"team_id": 7, "amount": 5_000_000}}
+		if mutate != nil {
+			mutate(&r)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		subject    Subject
+		action     string
+		resource   Resource
+		wantAllow  bool
+		wantPolicy string
+	}{
+		{"teammate reads invoice", staff, "invoice:read", invoice(nil), true, "team-read"},
+		{"other team cannot read", staff, "invoice:read", invoice(func(r *Resource) { r.Attributes["team_id"] = 8 }), false, ""},
+		{"resource type filter", staff, "invoice:read", invoice(func(r *Resource) { r.Type = "report" }), false, ""},
+		{"owner updates below limit", staff, "invoice:update", invoice(nil), true, "owner-update"},
+		{"owner cannot update above limit", staff, "invoice:update", invoice(func(r *Resource) { r.Attributes["amount"] = 10_000_000 }), false, ""},
+		{"non-owner cannot update", staff, "invoice:update", invoice(func(r *Resource) { r.OwnerID = "user-2" }), false, ""},
+		{"admin allowed any action", admin, "invoice:delete", invoice(nil), true, "admin"},
+		// Deny-overrides: policy deny menang walau ada policy allow yang cocok.
+		{"deny overrides admin allow", admin, "invoice:delete", invoice(func(r *Resource) { r.Attributes["locked"] = true }), false, "locked"},
+		{"deny overrides team allow", staff, "invoice:read", invoice(func(r *Resource) { r.Attributes["locked"] = true }), false, "locked"},
+		{"deny scoped to its actions", admin, "report:read", Resource{Type: "report", TenantID: "tenant-a", Attributes: map[string]interface{}{"locked": true}}, true, "admin"},
+		// Isolasi tenant berlaku sebelum policy apa pun, termasuk untuk admin.
+		{"other tenant denied for admin", admin, "invoice:read", invoice(func(r *Resource) { r.TenantID = "tenant-b" }), false, ""},
+		{"missing tenant denied for admin", admin, "invoice:read", invoice(func(r *Resource) { r.TenantID = "" }), false, ""},
+		{"global type without tenant", staff, "currency:read", Resource{Type: "currency", ID: "IDR"}, true, "currency-read"},
+		{"global type still isolated when tenant set", staff, "currency:read", Resource{Type: "currency", ID: "IDR", TenantID: "tenant-b"}, false, ""},
+		{"no policy matches", staff, "invoice:approve", invoice(nil), false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := e.Evaluate(&Request{Subject: tt.subject, Action: tt.action, Resource: tt.resource})
+			if got.Allowed != tt.wantAllow || got.PolicyID != tt.wantPolicy {
+				t.Errorf("Evaluate = %+v, want allowed=%v policy=%q", got, tt.wantAllow, tt.wantPolicy)
+			}
+			if got.Reason == "" {
+				t.Error("decision has no reason")
+			}
+		})
+	}
+}
+
+func TestEngineAuthorize(t *testing.T) {
+	e := NewEngine(testPolicies(), WithLogger(zerolog.Nop()))
+	claims := &auth.PrismClaims{TenantID: "tenant-a", Role: "staff", Attributes: map[string]interface{}{"team_id": float64(7)}}
+	claims.Subject = "user-1"
+	ctx := auth.WithIdentity(context.Background(), auth.NewIdentityFromClaims(claims))
+	resource := Resource{Type: "invoice", TenantID: "tenant-a", Attributes: map[string]interface{}{"team_id": 7}}
+
+	if err := e.Authorize(ctx, "invoice:read", resource); err != nil {
+		t.Errorf("Authorize(invoice:read) = %v, want nil", err)
+	}
+	var denied *DeniedError
+	if err := e.Authorize(ctx, "invoice:delete", resource); !errors.As(err, &denied) {
+		t.Errorf("Authorize(invoice:delete) = %v, want *DeniedError", err)
+	}
+	if err := e.Authorize(context.Background(), "invoice:read", resource); !errors.Is(err, ErrNoSubject) {
+		t.Errorf("Authorize without identity = %v, want %v", err, ErrNoSubject)
+	}
+
+	// Enricher menambahkan atribut yang tidak ada di token.
+	enriched := NewEngine(testPolicies(), WithLogger(zerolog.Nop()), WithSubjectEnricher(func(_ context.Context, s *Subject) error {
+		s.Attributes = map[string]interface{}{"team_id": 8}
+		return nil
+	}))
+	if err := enriched.Authorize(ctx, "invoice:read", resource); !errors.As(err, &denied) {
+		t.Errorf("Authorize with enriched team = %v, want *DeniedError", err)
+	}
+	failing := NewEngine(testPolicies(), WithLogger(zerolog.Nop()), WithSubjectEnricher(func(context.Context, *Subject) error {
+		return errors.New("db down")
+	}))
+	if err := failing.Authorize(ctx, "invoice:read", resource); err == nil || errors.As(err, &denied) {
+		t.Errorf("Authorize with failing enricher = %v, want a non-denial error", err)
+	}
+}
+
+func TestEngineRequire(t *testing.T) {
+	policies := append(testPolicies(), Policy{ID: "office", Effect: Allow, Actions: []string{"payroll:read"}, Condition: FromNetworks("10.0.0.0/8")})
+	e := NewEngine(policies, WithLogger(zerolog.Nop()))
+	claims := &auth.PrismClaims{TenantID: "tenant-a", Role: "staff", Attributes: map[string]interface{}{"team_id": float64(7)}}
+	claims.Subject = "user-1"
+
+	loadInvoice := func(c *gin.Context) (Resource, error) {
+		switch c.Query("id") {
+		case "missing":
+			return Resource{}, &auth.AuthError{Status: http.StatusNotFound, Message: "Invoice not found"}
+		case "broken":
+			return Resource{}, errors.New("db down")
+		case "other-team":
+			return Resource{Type: "invoice", TenantID: "tenant-a", Attributes: map[string]interface{}{"team_id": 8}}, nil
+		}
+		return Resource{Type: "invoice", TenantID: "tenant-a", Attributes: map[string]interface{}{"team_id": 7}}, nil
+	}
+	loadPayroll := func(*gin.Context) (Resource, error) {
+		return Resource{Type: "payroll", TenantID: "tenant-a"}, nil
+	}
+
+	tests := []struct {
+		name       string
+		withClaims bool
+		action     string
+		load       ResourceLoader
+		query      string
+		remoteAddr string
+		want       int
+	}{
+		{"allowed", true, "invoice:read", loadInvoice, "", "", http.StatusOK},
+		{"denied", true, "invoice:read", loadInvoice, "?id=other-team", "", http.StatusForbidden},
+		{"loader auth error", true, "invoice:read", loadInvoice, "?id=missing", "", http.StatusNotFound},
+		{"loader error", true, "invoice:read", loadInvoice, "?id=broken", "", http.StatusInternalServerError},
+		{"no claims", false, "invoice:read", loadInvoice, "", "", http.StatusForbidden},
+		{"client IP inside network", true, "payroll:read", loadPayroll, "", "10.1.2.3:5555", http.StatusOK},
+		{"client IP outside network", true, "payroll:read", loadPayroll, "", "203.0.113.9:5555", http.StatusForbidden},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				if tt.withClaims {
+					c.Set(auth.PrismClaimsKey, claims)
+				}
+			}, e.Require(tt.action, tt.load), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	// gin.Context tanpa Request (misal dibuat manual di worker) tidak boleh panic.
+	c := &gin.Context{}
+	c.Set(auth.PrismClaimsKey, claims)
+	if _, err := e.Decide(c, "invoice:read", Resource{Type: "invoice", TenantID: "tenant-a"}); err != nil {
+		t.Errorf("Decide on gin.Context without request: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package abac
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// All cocok jika semua kondisi cocok.
+func All(conditions ...Condition) Condition {
+	return func(r *Request) bool {
+		for _, c := range conditions {
+			if !c(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any cocok jika minimal satu kondisi cocok.
+func Any(conditions ...Condition) Condition {
+	return func(r *Request) bool {
+		for _, c := range conditions {
+			if c(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not membalik kondisi.
+func Not(condition Condition) Condition {
+	return func(r *Request) bool { return !condition(r) }
+}
+
+// SubjectHasRole cocok jika subjek memiliki role.
+func SubjectHasRole(role string) Condition {
+	return func(r *Request) bool { return r.Subject.HasRole(role) }
+}
+
+// SubjectIsOwner cocok jika resource dimiliki subjek.
+func SubjectIsOwner() Condition {
+	return func(r *Request) bool {
+		return r.Resource.OwnerID != "" && r.Resource.OwnerID == r.Subject.UserID
+	}
+}
+
+// AttributesEqual cocok jika atribut subjek sama dengan atribut resource, misalnya
+// AttributesEqual("team_id", "team_id"). Atribut yang tidak ada tidak pernah cocok.
+func AttributesEqual(subjectAttr, resourceAttr string) Condition {
+	return func(r *Request) bool {
+		s, ok := r.Subject.Attributes[subjectAttr]
+		if !ok {
+			return false
+		}
+		v, ok := r.Resource.Attributes[resourceAttr]
+		return ok && equalValues(s, v)
+	}
+}
+
+// ResourceAttributeBelow cocok jika atribut numerik resource < limit, misalnya ResourceAttributeBelow("amount", 10_000_000).
+func ResourceAttributeBelow(name string, limit float64) Condition {
+	return func(r *Request) bool {
+		v, ok := toFloat(r.Resource.Attributes[name])
+		return ok && v < limit
+	}
+}
+
+// ResourceAttributeAtMost cocok jika atribut numerik resource <= atribut numerik subjek,
+// misalnya ResourceAttributeAtMost("amount", "approval_limit").
+func ResourceAttributeAtMost(resourceAttr, subjectAttr string) Condition {
+	return func(r *Request) bool {
+		v, ok := toFloat(r.Resource.Attributes[resourceAttr])
+		if !ok {
+			return false
+		}
+		limit, ok := toFloat(r.Subject.Attributes[subjectAttr])
+		return ok && v <= limit
+	}
+}
+
+// FromNetworks cocok jika IP klien berada di salah satu CIDR. Panic jika CIDR tidak valid,
+// karena policy didefinisikan saat startup.
+func FromNetworks(cidrs ...string) Condition {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("abac: invalid CIDR %q: %v", cidr, err))
+		}
+		networks = append(networks, network)
+	}
+	return func(r *Request) bool {
+		ip := net.ParseIP(r.Environment.IP)
+		if ip == nil {
+			return false
+		}
+		for _, n := range networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// WithinHours cocok jika waktu request berada di [fromHour, toHour) pada zona loc,
+// misalnya WithinHours(8, 18, jakarta) untuk jam kerja. Rentang boleh melewati tengah malam.
+func WithinHours(fromHour, toHour int, loc *time.Location) Condition {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return func(r *Request) bool {
+		h := r.Environment.Time.In(loc).Hour()
+		if fromHour <= toHour {
+			return h >= fromHour && h < toHour
+		}
+		return h >= fromHour || h < toHour
+	}
+}
+
+// equalValues membandingkan dua atribut; angka dibandingkan sebagai float64 karena atribut
+// dari token (JSON) selalu float64 sementara atribut dari handler bisa int.
+func equalValues(a, b interface{}) bool {
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if okA && okB {
+		return fa == fb
+	}
+	if okA != okB {
+		return false
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
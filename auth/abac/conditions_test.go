@@ -0,0 +1,82 @@
+package abac
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConditions(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	req := &Request{
+		Subject: Subject{
+			UserID:     "user-1",
+			Roles:      []string{"staff", "approver"},
+			Attributes: map[string]interface{}{"team_id": float64(7), "approval_limit": float64(1000), "region": "jkt"},
+		},
+		Resource: Resource{
+			OwnerID:    "user-1",
+			Attributes: map[string]interface{}{"team_id": 7, "amount": 1000, "region": "jkt", "label": "7"},
+		},
+		// 02:30 UTC = 09:30 WIB.
+		Environment: Environment{IP: "10.20.30.40", Time: time.Date(2026, 1, 5, 2, 30, 0, 0, time.UTC)},
+	}
+	never := func(*Request) bool { return false }
+	always := func(*Request) bool { return true }
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+	}{
+		{"all empty", All(), true},
+		{"all", All(always, SubjectHasRole("staff")), true},
+		{"all short", All(always, never), false},
+		{"any empty", Any(), false},
+		{"any", Any(never, SubjectHasRole("approver")), true},
+		{"not", Not(never), true},
+		{"has role", SubjectHasRole("approver"), true},
+		{"missing role", SubjectHasRole("admin"), false},
+		{"owner", SubjectIsOwner(), true},
+		// Angka dari token (float64) sama dengan angka dari handler (int).
+		{"attributes equal across number types", AttributesEqual("team_id", "team_id"), true},
+		{"string attributes equal", AttributesEqual("region", "region"), true},
+		{"number does not equal its string form", AttributesEqual("team_id", "label"), false},
+		{"missing subject attribute", AttributesEqual("department", "team_id"), false},
+		{"missing resource attribute", AttributesEqual("team_id", "department"), false},
+		{"below limit", ResourceAttributeBelow("amount", 1001), true},
+		{"not below equal limit", ResourceAttributeBelow("amount", 1000), false},
+		{"below with non-numeric attribute", ResourceAttributeBelow("region", 1e9), false},
+		{"at most subject limit", ResourceAttributeAtMost("amount", "approval_limit"), true},
+		{"at most with missing subject limit", ResourceAttributeAtMost("amount", "budget"), false},
+		{"from network", FromNetworks("192.168.0.0/16", "10.0.0.0/8"), true},
+		{"outside networks", FromNetworks("192.168.0.0/16"), false},
+		{"within hours in zone", WithinHours(8, 18, jakarta), true},
+		{"outside hours in UTC", WithinHours(8, 18, nil), false},
+		{"range across midnight", WithinHours(22, 6, nil), true},
+		{"outside range across midnight", WithinHours(22, 6, jakarta), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition(req); got != tt.want {
+				t.Errorf("condition = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	ownerless := &Request{Subject: Subject{UserID: ""}}
+	if SubjectIsOwner()(ownerless) {
+		t.Error("SubjectIsOwner matched a resource without owner")
+	}
+	if FromNetworks("10.0.0.0/8")(&Request{Environment: Environment{IP: "not-an-ip"}}) {
+		t.Error("FromNetworks matched an invalid IP")
+	}
+}
+
+func TestFromNetworksPanicsOnInvalidCIDR(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("FromNetworks did not panic for an invalid CIDR")
+		}
+	}()
+	FromNetworks("10.0.0.0/33")
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrAPIKeyNotFound dikembalikan APIKeyStore ketika tidak ada key dengan prefix tersebut.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// StoredAPIKey adalah API key seperti yang disimpan oleh service pemiliknya: metadata publik
+// ditambah hash rahasia dan hak akses yang diberikan ke key tersebut.
+type StoredAPIKey struct {
+	model.APIKeyMetadata
+	SecretHash []byte // HashAPIKey dari key lengkap
+	Role       string
+	// Permissions (opsional) membatasi key ke sebagian izin Role: RBACMiddleware hanya
+	// memberikan izin yang dimiliki Role dan tercakup Permissions. Kosong berarti semua izin Role.
+	Permissions []string
+	Scopes      []string
+}
+
+// String menyembunyikan rahasia saat StoredAPIKey tidak sengaja dicetak ke log.
+func (k StoredAPIKey) String() string {
+	return fmt.Sprintf("APIKey(id=%s, prefix=%s)", k.ID, k.Prefix)
+}
+
+// APIKeyStore adalah penyimpanan API key yang disediakan oleh service.
+type APIKeyStore interface {
+	FindAPIKeyByPrefix(ctx context.Context, prefix string) (*StoredAPIKey, error)
+	// UpdateLastUsed menerima batch key ID -> waktu pemakaian terakhir.
+	UpdateLastUsed(ctx context.Context, usage map[string]time.Time) error
+}
+
+// HashAPIKey menghasilkan hash SHA-256 dari API key lengkap. API key memiliki entropi tinggi
+// sehingga hash cepat tanpa salt sudah memadai.
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// GenerateAPIKey membuat API key baru berformat "<prefix>.<secret>". Hanya prefix dan hash
+// yang boleh disimpan; key lengkap ditampilkan sekali ke pengguna.
+func GenerateAPIKey(prefixLabel string) (key, prefix string, hash []byte, err error) {
+	idBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", nil, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", nil, err
+	}
+	prefix = prefixLabel + base64.RawURLEncoding.EncodeToString(idBytes)
+	key = prefix + "." + base64.RawURLEncoding.EncodeToString(secretBytes)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// APIKeyConfig mengatur APIKeyAuthenticator.
+type APIKeyConfig struct {
+	Store APIKeyStore
+	// Header khusus untuk API key. Default "X-API-Key". "Authorization: ApiKey <key>" selalu didukung.
+	Header string
+	// FlushInterval adalah interval penulisan batch LastUsedAt. Default 30 detik.
+	FlushInterval time.Duration
+	// ErrorResponder opsional; default DefaultErrorResponder.
+	ErrorResponder ErrorResponder
+}
+
+// APIKeyAuthenticator memvalidasi API key dan mengisi identitas yang sama dengan JWTMiddleware.
+type APIKeyAuthenticator struct {
+	cfg APIKeyConfig
+
+	mu    sync.Mutex
+	usage map[string]time.Time
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewAPIKeyAuthenticator membuat authenticator dan memulai flusher LastUsedAt di background.
+// Panggil Close saat shutdown agar pemakaian terakhir tetap tersimpan.
+func NewAPIKeyAuthenticator(cfg APIKeyConfig) *APIKeyAuthenticator {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 30 * time.Second
+	}
+	if cfg.ErrorResponder == nil {
+		cfg.ErrorResponder = DefaultErrorResponder
+	}
+	a := &APIKeyAuthenticator{
+		cfg:   cfg,
+		usage: make(map[string]time.Time),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go a.flushLoop()
+	return a
+}
+
+// Middleware mengembalikan gin.HandlerFunc yang mewajibkan API key yang valid.
+func (a *APIKeyAuthenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := a.extractKey(c)
+		if rawKey == "" {
+			a.cfg.ErrorResponder(c, &AuthError{Status: http.StatusUnauthorized, Message: "API key required"})
+			c.Abort()
+			return
+		}
+
+		identity, authErr := a.verify(c.Request.Context(), rawKey)
+		if authErr != nil {
+			a.cfg.ErrorResponder(c, authErr)
+			c.Abort()
+			return
+		}
+
+		setGinIdentity(c, identity)
+		c.Next()
+	}
+}
+
+// Name mengimplementasikan Authenticator.
+func (a *APIKeyAuthenticator) Name() string {
+	return AuthMethodAPIKey
+}
+
+// Authenticate mengimplementasikan Authenticator untuk dipakai dalam Chain.
+func (a *APIKeyAuthenticator) Authenticate(c *gin.Context) (*Identity, *AuthError) {
+	rawKey := a.extractKey(c)
+	if rawKey == "" {
+		return nil, nil
+	}
+	return a.verify(c.Request.Context(), rawKey)
+}
+
+// extractKey membaca key dari header khusus atau dari "Authorization: ApiKey <key>".
+func (a *APIKeyAuthenticator) extractKey(c *gin.Context) string {
+	if key := c.GetHeader(a.cfg.Header); key != "" {
+		return key
+	}
+	const scheme = "apikey "
+	authHeader := c.GetHeader("Authorization")
+	if len(authHeader) > len(scheme) && strings.EqualFold(authHeader[:len(scheme)], scheme) {
+		return strings.TrimSpace(authHeader[len(scheme):])
+	}
+	return ""
+}
+
+// verify memvalidasi API key mentah dan membangun Identity untuknya.
+func (a *APIKeyAuthenticator) verify(ctx context.Context, rawKey string) (*Identity, *AuthError) {
+	prefix, _, found := strings.Cut(rawKey, ".")
+	if !found || prefix == "" {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid API key"}
+	}
+
+	stored, err := a.cfg.Store.FindAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid API key"}
+	}
+	if err != nil {
+		return nil, &AuthError{Status: http.StatusInternalServerError, Message: "Could not verify API key"}
+	}
+
+	if subtle.ConstantTimeCompare(HashAPIKey(rawKey), stored.SecretHash) != 1 {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid API key"}
+	}
+	now := time.Now()
+	if stored.RevokedAt != nil && !stored.RevokedAt.After(now) {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "API key has been revoked"}
+	}
+	if stored.ExpiresAt != nil && !stored.ExpiresAt.After(now) {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "API key has expired"}
+	}
+	if stored.UserID == "" || stored.TenantID == "" {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "API key is not bound to a user and tenant"}
+	}
+
+	a.recordUsage(stored.ID, now)
+
+	// Claims sintetis agar RBACMiddleware dan ClaimsFromContext bekerja sama seperti untuk JWT.
+	claims := &PrismClaims{
+		TenantID:    stored.TenantID,
+		Role:        stored.Role,
+		Permissions: stored.Permissions,
+		Scopes:      stored.Scopes,
+	}
+	claims.Subject = stored.UserID
+	claims.ID = stored.ID
+	identity := NewIdentityFromClaims(claims)
+	identity.AuthMethod = AuthMethodAPIKey
+	return identity, nil
+}
+
+func (a *APIKeyAuthenticator) recordUsage(keyID string, at time.Time) {
+	a.mu.Lock()
+	a.usage[keyID] = at
+	a.mu.Unlock()
+}
+
+func (a *APIKeyAuthenticator) flushLoop() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			a.flush()
+			return
+		case <-ticker.C:
+			a.flush()
+		}
+	}
+}
+
+// flush menulis batch LastUsedAt ke store. Kegagalan hanya dicatat; LastUsedAt bersifat informatif.
+func (a *APIKeyAuthenticator) flush() {
+	a.mu.Lock()
+	if len(a.usage) == 0 {
+		a.mu.Unlock()
+		return
+	}
+	batch := a.usage
+	a.usage = make(map[string]time.Time)
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.cfg.Store.UpdateLastUsed(ctx, batch); err != nil {
+		log.Printf("Peringatan: gagal memperbarui last_used_at untuk %d API key: %v", len(batch), err)
+	}
+}
+
+// Close menghentikan flusher dan menulis sisa batch LastUsedAt.
+func (a *APIKeyAuthenticator) Close() {
+	a.stopOnce.Do(func() { close(a.stop) })
+	<-a.done
+}
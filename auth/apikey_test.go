@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/model"
+	"github.com/gin-gonic/gin"
+)
+
+// memoryAPIKeyStore adalah APIKeyStore di memori untuk pengujian.
+type memoryAPIKeyStore struct {
+	mu    sync.Mutex
+	keys  map[string]*StoredAPIKey
+	err   error
+	usage map[string]time.Time
+}
+
+func (s *memoryAPIKeyStore) FindAPIKeyByPrefix(_ context.Context, prefix string) (*StoredAPIKey, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	key, ok := s.keys[prefix]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (s *memoryAPIKeyStore) UpdateLastUsed(_ context.Context, usage map[string]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, at := range usage {
+		s.usage[id] = at
+	}
+	return nil
+}
+
+func newTestAPIKey(t *testing.T, store *memoryAPIKeyStore, id string, mutate func(*StoredAPIKey)) string {
+	t.Helper()
+	key, prefix, hash, err := GenerateAPIKey("pk_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := &StoredAPIKey{
+		APIKeyMetadata: model.APIKeyMetadata{ID: id, TenantID: "tenant-a", UserID: "user-1", Prefix: prefix},
+		SecretHash:     hash,
+		Role:           "admin",
+	}
+	if mutate != nil {
+		mutate(stored)
+	}
+	store.keys[prefix] = stored
+	return key
+}
+
+func serveAPIKey(handlers []gin.HandlerFunc, key string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusNoContent) })...)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	store := &memoryAPIKeyStore{keys: make(map[string]*StoredAPIKey), usage: make(map[string]time.Time)}
+	past := time.Now().Add(-time.Minute)
+
+	valid := newTestAPIKey(t, store, "key-valid", nil)
+	revoked := newTestAPIKey(t, store, "key-revoked", func(k *StoredAPIKey) { k.RevokedAt = &past })
+	expired := newTestAPIKey(t, store, "key-expired", func(k *StoredAPIKey) { k.ExpiresAt = &past })
+	unbound := newTestAPIKey(t, store, "key-unbound", func(k *StoredAPIKey) { k.TenantID = "" })
+	prefix, _, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"valid", valid, http.StatusNoContent},
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong secret", prefix + ".wrong", http.StatusUnauthorized},
+		{"no separator", "garbage", http.StatusUnauthorized},
+		{"unknown prefix", "pk_unknown.secret", http.StatusUnauthorized},
+		{"revoked", revoked, http.StatusUnauthorized},
+		{"expired", expired, http.StatusUnauthorized},
+		{"not bound to tenant", unbound, http.StatusUnauthorized},
+	}
+	a := NewAPIKeyAuthenticator(APIKeyConfig{Store: store, FlushInterval: time.Hour})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveAPIKey([]gin.HandlerFunc{a.Middleware()}, tt.key); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Close menulis sisa batch LastUsedAt sebelum kembali.
+	a.Close()
+	if _, ok := store.usage["key-valid"]; !ok {
+		t.Error("LastUsedAt of the valid key was not flushed on Close")
+	}
+	if _, ok := store.usage["key-revoked"]; ok {
+		t.Error("LastUsedAt recorded for a revoked key")
+	}
+
+	store.err = errors.New("db down")
+	b := NewAPIKeyAuthenticator(APIKeyConfig{Store: store})
+	defer b.Close()
+	if got := serveAPIKey([]gin.HandlerFunc{b.Middleware()}, valid); got != http.StatusInternalServerError {
+		t.Errorf("store error: status = %d, want %d", got, http.StatusInternalServerError)
+	}
+}
+
+func TestAPIKeyPermissionsScopeRole(t *testing.T) {
+	store := &memoryAPIKeyStore{keys: make(map[string]*StoredAPIKey), usage: make(map[string]time.Time)}
+	full := newTestAPIKey(t, store, "key-full", nil)
+	scoped := newTestAPIKey(t, store, "key-scoped", func(k *StoredAPIKey) {
+		k.Permissions = []string{"invoice:read"}
+	})
+	beyondRole := newTestAPIKey(t, store, "key-beyond", func(k *StoredAPIKey) {
+		k.Role = "staff"
+		k.Permissions = []string{"user:delete"}
+	})
+
+	a := NewAPIKeyAuthenticator(APIKeyConfig{Store: store})
+	defer a.Close()
+	m := NewRBACMiddlewareWithSource(NewMemoryPermissionSource(RolePermissions{
+		"admin": {"*"},
+		"staff": {"invoice:read"},
+	}), time.Minute)
+	defer m.Close()
+
+	tests := []struct {
+		name       string
+		key        string
+		permission string
+		want       int
+	}{
+		{"unscoped key has every role permission", full, "user:delete", http.StatusNoContent},
+		{"scoped key keeps listed permission", scoped, "invoice:read", http.StatusNoContent},
+		{"scoped key loses other role permissions", scoped, "user:delete", http.StatusForbidden},
+		{"scope cannot exceed the role", beyondRole, "user:delete", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := serveAPIKey([]gin.HandlerFunc{a.Middleware(), m.RequirePermission(tt.permission)}, tt.key)
+			if got != tt.want {
+				t.Errorf("RequirePermission(%q): status = %d, want %d", tt.permission, got, tt.want)
+			}
+			got = serveAPIKey([]gin.HandlerFunc{a.Middleware(), m.RequireAny(tt.permission)}, tt.key)
+			if got != tt.want {
+				t.Errorf("RequireAny(%q): status = %d, want %d", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyMiddlewareIdentity(t *testing.T) {
+	store := &memoryAPIKeyStore{keys: make(map[string]*StoredAPIKey), usage: make(map[string]time.Time)}
+	key := newTestAPIKey(t, store, "key-1", func(k *StoredAPIKey) { k.Scopes = []string{"invoice:read"} })
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"custom header", "X-Prism-Key", key, http.StatusOK},
+		{"authorization apikey scheme", "Authorization", "ApiKey " + key, http.StatusOK},
+		{"scheme is case-insensitive", "Authorization", "apikey " + key, http.StatusOK},
+		{"bearer is not an api key", "Authorization", "Bearer " + key, http.StatusUnauthorized},
+		{"default header not used when overridden", "X-API-Key", key, http.StatusUnauthorized},
+	}
+	a := NewAPIKeyAuthenticator(APIKeyConfig{Store: store, Header: "X-Prism-Key"})
+	defer a.Close()
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity *Identity
+			var tenantID string
+			r := gin.New()
+			r.GET("/", a.Middleware(), func(c *gin.Context) {
+				identity, _ = IdentityFrom(c)
+				tenantID, _ = GetTenantID(c)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if identity == nil || identity.UserID != "user-1" || identity.TenantID != "tenant-a" || identity.AuthMethod != AuthMethodAPIKey {
+				t.Errorf("identity = %+v, want user-1 in tenant-a via %s", identity, AuthMethodAPIKey)
+			}
+			if identity != nil && (identity.Claims == nil || !identity.Claims.HasScope("invoice:read")) {
+				t.Error("identity claims do not carry the key's scopes")
+			}
+			if tenantID != "tenant-a" {
+				t.Errorf("GetTenantID = %q, want %q", tenantID, "tenant-a")
+			}
+		})
+	}
+}
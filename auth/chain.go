@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticator adalah satu metode otentikasi di dalam Chain.
+//
+// Authenticate mengembalikan (nil, nil) jika request tidak membawa kredensial jenis ini,
+// sehingga authenticator berikutnya dicoba. Jika kredensial ada tetapi tidak valid,
+// AuthError dikembalikan dan chain berhenti; kredensial yang salah tidak boleh
+// "turun" ke metode lain.
+type Authenticator interface {
+	Name() string
+	Authenticate(c *gin.Context) (*Identity, *AuthError)
+}
+
+// Chain membuat middleware yang mencoba authenticators secara berurutan. Metode yang berhasil
+// dicatat di Identity.AuthMethod, sehingga caller mesin-ke-mesin dan pengguna browser bisa
+// memakai endpoint yang sama.
+func Chain(authenticators ...Authenticator) gin.HandlerFunc {
+	return ChainWithResponder(DefaultErrorResponder, authenticators...)
+}
+
+// ChainWithResponder sama seperti Chain dengan ErrorResponder khusus.
+func ChainWithResponder(responder ErrorResponder, authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, a := range authenticators {
+			identity, authErr := a.Authenticate(c)
+			if authErr != nil {
+				responder(c, authErr)
+				c.Abort()
+				return
+			}
+			if identity == nil {
+				continue
+			}
+			if identity.AuthMethod == "" {
+				identity.AuthMethod = a.Name()
+			}
+			setGinIdentity(c, identity)
+			c.Next()
+			return
+		}
+
+		responder(c, &AuthError{Status: http.StatusUnauthorized, Message: "Authentication required"})
+		c.Abort()
+	}
+}
+
+// jwtAuthenticator adalah Authenticator untuk bearer JWT.
+type jwtAuthenticator struct {
+	opts *jwtOptions
+}
+
+// NewJWTAuthenticator membuat Authenticator bearer JWT dengan Option yang sama seperti NewJWTMiddleware.
+// Header dengan skema lain (misal "Authorization: ApiKey ...") dianggap bukan milik authenticator ini.
+// Panic jika issuer atau audience tidak dikonfigurasi; lihat NewJWTMiddleware.
+func NewJWTAuthenticator(opts ...Option) Authenticator {
+	o := newJWTOptions(opts)
+	return &jwtAuthenticator{opts: o}
+}
+
+func (a *jwtAuthenticator) Name() string {
+	return AuthMethodJWT
+}
+
+func (a *jwtAuthenticator) Authenticate(c *gin.Context) (*Identity, *AuthError) {
+	var tokenString string
+	for _, lookup := range a.opts.lookups {
+		token, authErr := lookup.Extract(c)
+		if authErr == nil && token != "" {
+			tokenString = token
+			break
+		}
+	}
+	if tokenString == "" {
+		return nil, nil
+	}
+
+	claims, mapClaims, authErr := a.opts.verify(c.Request.Context(), tokenString)
+	if authErr != nil {
+		return nil, authErr
+	}
+	c.Set(ClaimsKey, mapClaims)
+	return NewIdentityFromClaims(claims), nil
+}
+
+// MTLSAuthenticator mengotentikasi klien berdasarkan sertifikat klien TLS yang sudah
+// diverifikasi oleh server (tls.Config.ClientAuth = VerifyClientCertIfGiven/RequireAndVerifyClientCert).
+type MTLSAuthenticator struct {
+	// MapCertificate memetakan sertifikat leaf ke Identity, misalnya dari SPIFFE ID di URI SAN.
+	MapCertificate func(cert *x509.Certificate) (*Identity, error)
+}
+
+func (a *MTLSAuthenticator) Name() string {
+	return AuthMethodMTLS
+}
+
+func (a *MTLSAuthenticator) Authenticate(c *gin.Context) (*Identity, *AuthError) {
+	tlsState := c.Request.TLS
+	// Hanya sertifikat yang lolos verifikasi rantai yang dipercaya.
+	if tlsState == nil || len(tlsState.VerifiedChains) == 0 || len(tlsState.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	identity, err := a.MapCertificate(tlsState.VerifiedChains[0][0])
+	if err != nil || identity == nil {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "Client certificate is not authorized", Err: err}
+	}
+	identity.AuthMethod = AuthMethodMTLS
+	return identity, nil
+}
+
+// ErrUnknownServiceToken dikembalikan StaticServiceTokens untuk token yang tidak terdaftar.
+var ErrUnknownServiceToken = errors.New("unknown service token")
+
+// ServiceTokenVerifier memvalidasi token internal antar-service dan mengembalikan identitas service.
+type ServiceTokenVerifier interface {
+	VerifyServiceToken(ctx context.Context, token string) (*Identity, error)
+}
+
+// ServiceTokenAuthenticator mengotentikasi panggilan internal yang membawa token service di header.
+type ServiceTokenAuthenticator struct {
+	Header   string // Default "X-Service-Token".
+	Verifier ServiceTokenVerifier
+}
+
+func (a *ServiceTokenAuthenticator) Name() string {
+	return AuthMethodServiceToken
+}
+
+func (a *ServiceTokenAuthenticator) Authenticate(c *gin.Context) (*Identity, *AuthError) {
+	header := a.Header
+	if header == "" {
+		header = "X-Service-Token"
+	}
+	token := c.GetHeader(header)
+	if token == "" {
+		return nil, nil
+	}
+	identity, err := a.Verifier.VerifyServiceToken(c.Request.Context(), token)
+	if err != nil || identity == nil {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid service token", Err: err}
+	}
+	identity.AuthMethod = AuthMethodServiceToken
+	return identity, nil
+}
+
+// StaticServiceTokens adalah ServiceTokenVerifier sederhana berbasis daftar token statis
+// (token -> nama service), misalnya yang dimuat dari Vault saat startup.
+type StaticServiceTokens map[string]string
+
+func (s StaticServiceTokens) VerifyServiceToken(_ context.Context, token string) (*Identity, error) {
+	// Bandingkan hash dengan constant-time agar waktu respons tidak membocorkan isi token.
+	presented := sha256.Sum256([]byte(token))
+	var matched string
+	for candidate, service := range s {
+		expected := sha256.Sum256([]byte(candidate))
+		if subtle.ConstantTimeCompare(presented[:], expected[:]) == 1 {
+			matched = service
+		}
+	}
+	if matched == "" {
+		return nil, ErrUnknownServiceToken
+	}
+	return &Identity{UserID: "service:" + matched, Role: "service"}, nil
+}
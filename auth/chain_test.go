@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestChain(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", testJWTSecret)
+	now := time.Now()
+	validJWT := signTestJWT(t, jwt.MapClaims{
+		"sub": "user-1", "tid": "tenant-a", "jti": "jti-1", "iss": "prism-auth", "aud": "billing",
+		"exp": now.Add(time.Minute).Unix(), "iat": now.Unix(),
+	})
+	expiredJWT := signTestJWT(t, jwt.MapClaims{
+		"sub": "user-1", "tid": "tenant-a", "jti": "jti-2", "iss": "prism-auth", "aud": "billing",
+		"exp": now.Add(-time.Minute).Unix(), "iat": now.Add(-time.Hour).Unix(),
+	})
+
+	store := &memoryAPIKeyStore{keys: make(map[string]*StoredAPIKey), usage: make(map[string]time.Time)}
+	apiKey := newTestAPIKey(t, store, "key-1", nil)
+	apiKeys := NewAPIKeyAuthenticator(APIKeyConfig{Store: store})
+	defer apiKeys.Close()
+
+	mtls := &MTLSAuthenticator{MapCertificate: func(cert *x509.Certificate) (*Identity, error) {
+		if cert.Subject.CommonName != "billing" {
+			return nil, errors.New("unknown service certificate")
+		}
+		return &Identity{UserID: "service:billing", Role: "service"}, nil
+	}}
+	verifiedTLS := func(cn string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	chain := Chain(
+		NewJWTAuthenticator(WithIssuer("prism-auth"), WithAudience("billing")),
+		apiKeys,
+		mtls,
+		&ServiceTokenAuthenticator{Verifier: StaticServiceTokens{"svc-secret": "billing"}},
+	)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		tls        *tls.ConnectionState
+		wantStatus int
+		wantMethod string
+		wantUser   string
+	}{
+		{"no credentials", nil, nil, http.StatusUnauthorized, "", ""},
+		{"bearer jwt", map[string]string{"Authorization": "Bearer " + validJWT}, nil, http.StatusOK, AuthMethodJWT, "user-1"},
+		{"api key scheme is not a bearer token", map[string]string{"Authorization": "ApiKey " + apiKey}, nil, http.StatusOK, AuthMethodAPIKey, "user-1"},
+		{"api key header", map[string]string{"X-API-Key": apiKey}, nil, http.StatusOK, AuthMethodAPIKey, "user-1"},
+		{"verified client certificate", nil, verifiedTLS("billing"), http.StatusOK, AuthMethodMTLS, "service:billing"},
+		{"service token", map[string]string{"X-Service-Token": "svc-secret"}, nil, http.StatusOK, AuthMethodServiceToken, "service:billing"},
+		{"first authenticator wins", map[string]string{"Authorization": "Bearer " + validJWT, "X-Service-Token": "svc-secret"}, nil, http.StatusOK, AuthMethodJWT, "user-1"},
+		// Kredensial yang ada tetapi salah menghentikan chain, walau metode berikutnya valid.
+		{"invalid jwt does not fall through", map[string]string{"Authorization": "Bearer " + expiredJWT, "X-Service-Token": "svc-secret"}, nil, http.StatusUnauthorized, "", ""},
+		{"invalid api key does not fall through", map[string]string{"X-API-Key": "pk_unknown.secret", "X-Service-Token": "svc-secret"}, nil, http.StatusUnauthorized, "", ""},
+		{"unmapped client certificate", nil, verifiedTLS("intruder"), http.StatusUnauthorized, "", ""},
+		{"unverified client certificate is ignored", nil, &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "billing"}}}}, http.StatusUnauthorized, "", ""},
+		{"unknown service token", map[string]string{"X-Service-Token": "guess"}, nil, http.StatusUnauthorized, "", ""},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity *Identity
+			r := gin.New()
+			r.GET("/", chain, func(c *gin.Context) {
+				identity, _ = IdentityFrom(c)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			req.TLS = tt.tls
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if identity == nil || identity.AuthMethod != tt.wantMethod || identity.UserID != tt.wantUser {
+				t.Errorf("identity = %+v, want %s via %s", identity, tt.wantUser, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestStaticServiceTokens(t *testing.T) {
+	tokens := StaticServiceTokens{"token-billing": "billing", "token-report": "report"}
+
+	identity, err := tokens.VerifyServiceToken(context.Background(), "token-report")
+	if err != nil {
+		t.Fatalf("VerifyServiceToken: %v", err)
+	}
+	if identity.UserID != "service:report" || identity.Role != "service" {
+		t.Errorf("identity = %+v, want service:report with role service", identity)
+	}
+	for _, token := range []string{"", "token-billin", "TOKEN-BILLING"} {
+		if _, err := tokens.VerifyServiceToken(context.Background(), token); !errors.Is(err, ErrUnknownServiceToken) {
+			t.Errorf("VerifyServiceToken(%q) error = %v, want %v", token, err, ErrUnknownServiceToken)
+		}
+	}
+}
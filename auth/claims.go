@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PrismClaimsKey adalah key gin context untuk *PrismClaims. ClaimsKey tetap berisi
+// jwt.MapClaims mentah demi kompatibilitas dengan handler lama.
+const PrismClaimsKey = "prism_claims"
+
+// PrismClaims adalah layout claim token akses Prism.
+type PrismClaims struct {
+	jwt.RegisteredClaims
+	TenantID string `json:"tid"`
+	Role     string `json:"role,omitempty"`
+	// Roles berisi role tambahan untuk user dengan lebih dari satu role; Role tetap role utama.
+	Roles []string `json:"roles,omitempty"`
+	// Permissions, jika terisi, membatasi izin role yang diberikan RBACMiddleware ke daftar ini.
+	Permissions []string `json:"permissions,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+	// TokenUse membedakan token akses, refresh, dan service; kosong dianggap token akses.
+	TokenUse string `json:"token_use,omitempty"`
+	// AMR adalah metode otentikasi yang dipakai saat login (RFC 8176), misalnya ["pwd", "otp"].
+	AMR []string `json:"amr,omitempty"`
+	// MFA ditandai true oleh issuer jika login melewati faktor kedua.
+	MFA bool `json:"mfa,omitempty"`
+	// Attributes adalah atribut subjek untuk ABAC, misalnya team_id atau approval_limit.
+	Attributes map[string]interface{} `json:"attrs,omitempty"`
+	// Actor diisi jika token adalah token impersonasi: Subject/TenantID adalah user yang
+	// diimpersonasi, Actor adalah operator yang sebenarnya bertindak.
+	Actor *ActorClaim `json:"act,omitempty"`
+}
+
+// ActorClaim adalah claim act (RFC 8693 bagian 4.1): pihak yang bertindak atas nama subjek token.
+// Act bersarang mencatat aktor sebelumnya dalam rantai delegasi.
+type ActorClaim struct {
+	Subject  string      `json:"sub"`
+	TenantID string      `json:"tid,omitempty"`
+	Act      *ActorClaim `json:"act,omitempty"`
+}
+
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+	// TokenUseService menandai token antar-service dari paket auth/servicetoken.
+	TokenUseService = "service"
+)
+
+// Nilai claim amr dari RFC 8176 yang dipakai Prism.
+const (
+	AMRPassword        = "pwd"
+	AMROneTimePassword = "otp"
+	AMRMultiFactor     = "mfa"
+)
+
+// HasScope memeriksa apakah token membawa scope tertentu.
+func (c *PrismClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllRoles mengembalikan Role dan Roles tanpa duplikat, dengan Role di urutan pertama.
+func (c *PrismClaims) AllRoles() []string {
+	roles := make([]string, 0, len(c.Roles)+1)
+	seen := make(map[string]struct{}, len(c.Roles)+1)
+	for _, r := range append([]string{c.Role}, c.Roles...) {
+		if r == "" {
+			continue
+		}
+		if _, dup := seen[r]; dup {
+			continue
+		}
+		seen[r] = struct{}{}
+		roles = append(roles, r)
+	}
+	return roles
+}
+
+// HasRole memeriksa apakah role ada di Role atau Roles.
+func (c *PrismClaims) HasRole(role string) bool {
+	for _, r := range c.AllRoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsImpersonated memeriksa apakah token dipakai operator yang bertindak sebagai user lain.
+func (c *PrismClaims) IsImpersonated() bool {
+	return c.Actor != nil
+}
+
+// HasMFA memeriksa apakah token diterbitkan setelah otentikasi multi-faktor,
+// baik lewat claim mfa maupun nilai amr "mfa"/"otp".
+func (c *PrismClaims) HasMFA() bool {
+	if c.MFA {
+		return true
+	}
+	for _, m := range c.AMR {
+		if m == AMRMultiFactor || m == AMROneTimePassword {
+			return true
+		}
+	}
+	return false
+}
+
+// ContextWithClaims menyimpan claims di context.Context biasa sebagai Identity.
+func ContextWithClaims(ctx context.Context, claims *PrismClaims) context.Context {
+	return WithIdentity(ctx, NewIdentityFromClaims(claims))
+}
+
+// ClaimsFromContext mengambil *PrismClaims yang disimpan oleh JWTMiddleware.
+// Menerima *gin.Context maupun context.Context biasa (misal c.Request.Context()).
+func ClaimsFromContext(ctx context.Context) (*PrismClaims, error) {
+	if ginCtx, ok := ctx.(*gin.Context); ok {
+		if val, exists := ginCtx.Get(PrismClaimsKey); exists {
+			if claims, ok := val.(*PrismClaims); ok && claims != nil {
+				return claims, nil
+			}
+			return nil, fmt.Errorf("claims in context have unexpected type %T", val)
+		}
+	}
+
+	identity, ok := IdentityFrom(ctx)
+	if !ok || identity.Claims == nil {
+		return nil, fmt.Errorf("claims not found in context, middleware might be missing")
+	}
+	return identity.Claims, nil
+}
+
+// newPrismClaims mengonversi MapClaims yang sudah tervalidasi menjadi PrismClaims,
+// menerapkan pemetaan nama claim dari ClaimNames.
+func newPrismClaims(mapClaims jwt.MapClaims, names ClaimNames) (*PrismClaims, error) {
+	raw, err := json.Marshal(mapClaims)
+	if err != nil {
+		return nil, err
+	}
+	claims := &PrismClaims{}
+	if err := json.Unmarshal(raw, claims); err != nil {
+		return nil, err
+	}
+
+	defaults := DefaultClaimNames()
+	if names.UserID != defaults.UserID {
+		claims.Subject, _ = mapClaims[names.UserID].(string)
+	}
+	if names.TenantID != defaults.TenantID {
+		claims.TenantID, _ = mapClaims[names.TenantID].(string)
+	}
+	if names.TokenID != defaults.TokenID {
+		claims.ID, _ = mapClaims[names.TokenID].(string)
+	}
+	return claims, nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Identity adalah identitas pemanggil yang sudah terotentikasi. Disimpan di context.Context
+// sehingga handler gin, handler gRPC, worker background, dan db.TenantDB membacanya dengan cara yang sama.
+type Identity struct {
+	UserID   string
+	TenantID string
+	Role     string
+	// AuthMethod mencatat metode otentikasi yang berhasil, misalnya AuthMethodJWT atau AuthMethodAPIKey.
+	AuthMethod string
+	// Actor adalah operator sebenarnya jika identitas ini hasil impersonasi; nil jika tidak.
+	Actor *ActorClaim
+	// Claims berisi claims token asal identitas ini (sintetis untuk API key); nil untuk identitas
+	// tanpa token, misalnya worker background yang memanggil WithIdentity secara manual.
+	Claims *PrismClaims
+}
+
+const (
+	AuthMethodJWT          = "jwt"
+	AuthMethodAPIKey       = "api_key"
+	AuthMethodMTLS         = "mtls"
+	AuthMethodServiceToken = "service_token"
+)
+
+// NewIdentityFromClaims membangun Identity dari claims token yang sudah tervalidasi.
+func NewIdentityFromClaims(claims *PrismClaims) *Identity {
+	return &Identity{
+		UserID:     claims.Subject,
+		TenantID:   claims.TenantID,
+		Role:       claims.Role,
+		AuthMethod: AuthMethodJWT,
+		Actor:      claims.Actor,
+		Claims:     claims,
+	}
+}
+
+// identityContextKey adalah key context yang tidak diekspor agar tidak bertabrakan dengan paket lain.
+type identityContextKey struct{}
+
+// WithIdentity mengembalikan context turunan yang membawa identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFrom mengambil Identity dari context. Untuk *gin.Context, identitas dibaca dari
+// c.Request.Context() yang diisi oleh JWTMiddleware.
+func IdentityFrom(ctx context.Context) (*Identity, bool) {
+	if ginCtx, ok := ctx.(*gin.Context); ok {
+		if ginCtx.Request == nil {
+			return nil, false
+		}
+		ctx = ginCtx.Request.Context()
+	}
+	identity, ok := ctx.Value(identityContextKey{}).(*Identity)
+	if !ok || identity == nil {
+		return nil, false
+	}
+	return identity, true
+}
+
+// setGinIdentity menyimpan identitas di request context dan di key gin lama
+// (user_id, tenant_id) agar GetUserID/GetTenantID tetap bekerja.
+func setGinIdentity(c *gin.Context, identity *Identity) {
+	c.Set(UserIDKey, identity.UserID)
+	c.Set(TenantIDKey, identity.TenantID)
+	if identity.Claims != nil {
+		c.Set(PrismClaimsKey, identity.Claims)
+	}
+	c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultImpersonationTTL adalah umur token impersonasi jika ttl tidak diberikan.
+	DefaultImpersonationTTL = 15 * time.Minute
+	// MaxImpersonationTTL adalah batas atas umur token impersonasi.
+	MaxImpersonationTTL = time.Hour
+)
+
+// ErrNestedImpersonation dikembalikan jika operator yang sedang mengimpersonasi mencoba
+// mengimpersonasi user lain lagi.
+var ErrNestedImpersonation = errors.New("cannot impersonate while already impersonating")
+
+// IssueImpersonationToken mencetak token akses berumur pendek untuk operator (misal staf support)
+// yang bertindak sebagai target di tenant target. Token membawa claim act berisi operator,
+// sid milik sesi operator (sehingga mengakhiri sesi operator juga mengakhiri impersonasi),
+// dan tidak disertai refresh token sehingga tidak bisa diperpanjang.
+// Token tidak membawa amr: MFA operator tidak dianggap sebagai MFA target, sehingga route
+// dengan RequireMFA tidak bisa diakses lewat impersonasi.
+// ttl <= 0 berarti DefaultImpersonationTTL; nilai di atas MaxImpersonationTTL dipotong.
+// Pemeriksaan apakah operator boleh mengimpersonasi target adalah tanggung jawab pemanggil.
+func (i *Issuer) IssueImpersonationToken(ctx context.Context, operator *PrismClaims, target TokenSubject, ttl time.Duration) (string, *PrismClaims, error) {
+	if operator == nil || operator.Subject == "" {
+		return "", nil, fmt.Errorf("impersonation requires an authenticated operator")
+	}
+	if operator.IsImpersonated() {
+		return "", nil, ErrNestedImpersonation
+	}
+	if target.UserID == "" || target.TenantID == "" {
+		return "", nil, fmt.Errorf("token subject requires user ID and tenant ID")
+	}
+	if target.UserID == operator.Subject && target.TenantID == operator.TenantID {
+		return "", nil, fmt.Errorf("operator cannot impersonate themselves")
+	}
+	if ttl <= 0 {
+		ttl = DefaultImpersonationTTL
+	}
+	if ttl > MaxImpersonationTTL {
+		ttl = MaxImpersonationTTL
+	}
+
+	target.Actor = &ActorClaim{Subject: operator.Subject, TenantID: operator.TenantID}
+	target.SessionID = operator.SessionID
+	target.AMR = nil
+	claims := i.baseClaims(target, TokenUseAccess, i.now(), ttl)
+	signed, err := i.sign(ctx, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// WithImpersonationRestrictions menolak izin sensitif untuk token impersonasi. Daftar blokir
+// selalu hierarkis agar gagal di sisi aman: "billing" juga memblokir "billing:refund", dan
+// "user:*:delete" memblokir "user:42:delete:all". Berlaku untuk RequirePermission, RequirePolicy,
+// RequireAny/RequireAll, dan interceptor gRPC.
+func WithImpersonationRestrictions(permissions ...string) RBACOption {
+	return func(m *RBACMiddleware) {
+		m.impersonationBlocked = newPermissionSet()
+		m.impersonationBlocked.hierarchical = true
+		for _, p := range permissions {
+			m.impersonationBlocked.add(p)
+		}
+	}
+}
+
+// blockedWhileImpersonating memeriksa apakah permission diblokir untuk claims impersonasi.
+func (m *RBACMiddleware) blockedWhileImpersonating(claims *PrismClaims, permission string) bool {
+	return m.impersonationBlocked != nil && claims.IsImpersonated() && m.impersonationBlocked.allows(permission)
+}
+
+// DenyImpersonation menolak semua request dengan token impersonasi, untuk route yang tidak
+// memakai RBAC tetapi tidak boleh dilakukan atas nama user lain (misal mengganti password).
+// Harus dipasang setelah JWTMiddleware.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := ClaimsFromContext(c)
+		if err != nil {
+			DefaultErrorResponder(c, &AuthError{Status: http.StatusForbidden, Message: "Claims not found in context"})
+			c.Abort()
+			return
+		}
+		if claims.IsImpersonated() {
+			DefaultErrorResponder(c, &AuthError{Status: http.StatusForbidden, Message: "Not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ImpersonationAuditEvent adalah catatan audit untuk satu request impersonasi.
+type ImpersonationAuditEvent struct {
+	OperatorID       string
+	OperatorTenantID string
+	UserID           string
+	TenantID         string
+	TokenID          string
+	// Method dan Path berisi metode dan path HTTP, atau "grpc" dan nama method lengkap.
+	Method   string
+	Path     string
+	Status   int // Status HTTP, atau kode gRPC.
+	Duration time.Duration
+	ClientIP string
+}
+
+// ImpersonationAuditSink menerima ImpersonationAuditEvent, misalnya untuk menulis ke tabel audit.
+type ImpersonationAuditSink func(ctx context.Context, event ImpersonationAuditEvent)
+
+// LogImpersonationAudit adalah sink default yang menulis event ke log.Default().
+func LogImpersonationAudit(ctx context.Context, event ImpersonationAuditEvent) {
+	ImpersonationAuditLogger(log.Default())(ctx, event)
+}
+
+// ImpersonationAuditLogger mengembalikan sink yang menulis event ke logger, misalnya logger
+// khusus audit yang diarahkan ke file atau collector terpisah.
+func ImpersonationAuditLogger(logger *log.Logger) ImpersonationAuditSink {
+	return func(_ context.Context, event ImpersonationAuditEvent) {
+		logger.Printf("Audit impersonasi: operator_id=%q operator_tenant_id=%q user_id=%q tenant_id=%q jti=%q method=%q path=%q status=%d duration=%s ip=%q",
+			event.OperatorID, event.OperatorTenantID, event.UserID, event.TenantID, event.TokenID,
+			event.Method, event.Path, event.Status, event.Duration, event.ClientIP)
+	}
+}
+
+func newImpersonationAuditEvent(claims *PrismClaims) ImpersonationAuditEvent {
+	return ImpersonationAuditEvent{
+		OperatorID:       claims.Actor.Subject,
+		OperatorTenantID: claims.Actor.TenantID,
+		UserID:           claims.Subject,
+		TenantID:         claims.TenantID,
+		TokenID:          claims.ID,
+	}
+}
+
+// ImpersonationAudit mencatat setiap request yang memakai token impersonasi setelah request
+// selesai, termasuk yang ditolak oleh middleware berikutnya. sink nil berarti LogImpersonationAudit.
+// Harus dipasang setelah JWTMiddleware dan sebelum middleware otorisasi.
+func ImpersonationAudit(sink ImpersonationAuditSink) gin.HandlerFunc {
+	if sink == nil {
+		sink = LogImpersonationAudit
+	}
+	return func(c *gin.Context) {
+		claims, err := ClaimsFromContext(c)
+		if err != nil || !claims.IsImpersonated() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		event := newImpersonationAuditEvent(claims)
+		event.Method = c.Request.Method
+		event.Path = c.Request.URL.Path
+		event.Status = c.Writer.Status()
+		event.Duration = time.Since(start)
+		event.ClientIP = c.ClientIP()
+		sink(c.Request.Context(), event)
+	}
+}
+
+// ImpersonationAuditUnaryInterceptor adalah padanan ImpersonationAudit untuk server gRPC.
+// Harus dipasang setelah interceptor dari GRPCAuthenticator.
+func ImpersonationAuditUnaryInterceptor(sink ImpersonationAuditSink) grpc.UnaryServerInterceptor {
+	if sink == nil {
+		sink = LogImpersonationAudit
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		claims, err := ClaimsFromContext(ctx)
+		if err != nil || !claims.IsImpersonated() {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		event := newImpersonationAuditEvent(claims)
+		event.Method = "grpc"
+		event.Path = info.FullMethod
+		event.Status = int(status.Code(err))
+		event.Duration = time.Since(start)
+		sink(ctx, event)
+		return resp, err
+	}
+}
+
+// ImpersonationAuditStreamInterceptor adalah versi streaming dari ImpersonationAuditUnaryInterceptor.
+// Event dicatat sekali saat stream selesai.
+func ImpersonationAuditStreamInterceptor(sink ImpersonationAuditSink) grpc.StreamServerInterceptor {
+	if sink == nil {
+		sink = LogImpersonationAudit
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		claims, err := ClaimsFromContext(ctx)
+		if err != nil || !claims.IsImpersonated() {
+			return handler(srv, ss)
+		}
+
+		start := time.Now()
+		err = handler(srv, ss)
+
+		event := newImpersonationAuditEvent(claims)
+		event.Method = "grpc"
+		event.Path = info.FullMethod
+		event.Status = int(status.Code(err))
+		event.Duration = time.Since(start)
+		sink(ctx, event)
+		return err
+	}
+}
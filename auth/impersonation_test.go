@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIssueImpersonationToken(t *testing.T) {
+	ctx := context.Background()
+	issuer, _ := newTestIssuer(t, IssuerConfig{})
+	operator := &PrismClaims{TenantID: "tenant-ops", SessionID: "sid-op", AMR: []string{AMRPassword, AMROneTimePassword}}
+	operator.Subject = "operator-1"
+	target := TokenSubject{UserID: "user-1", TenantID: "tenant-a", Role: "staff", AMR: []string{AMRMultiFactor}}
+	nested := impersonatedClaims("staff")
+
+	errorTests := []struct {
+		name     string
+		operator *PrismClaims
+		target   TokenSubject
+		wantErr  error
+	}{
+		{"no operator", nil, target, nil},
+		{"operator without subject", &PrismClaims{TenantID: "tenant-ops"}, target, nil},
+		{"nested impersonation", nested, TokenSubject{UserID: "user-2", TenantID: "tenant-a"}, ErrNestedImpersonation},
+		{"target without tenant", operator, TokenSubject{UserID: "user-1"}, nil},
+		{"self", operator, TokenSubject{UserID: "operator-1", TenantID: "tenant-ops"}, nil},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := issuer.IssueImpersonationToken(ctx, tt.operator, tt.target, 0)
+			if err == nil {
+				t.Fatal("IssueImpersonationToken returned nil error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	ttlTests := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"default", 0, DefaultImpersonationTTL},
+		{"custom", 5 * time.Minute, 5 * time.Minute},
+		{"capped", 24 * time.Hour, MaxImpersonationTTL},
+	}
+	for _, tt := range ttlTests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, claims, err := issuer.IssueImpersonationToken(ctx, operator, target, tt.ttl)
+			if err != nil {
+				t.Fatalf("IssueImpersonationToken: %v", err)
+			}
+			parsed := parseTestToken(t, token)
+			if got := parsed.ExpiresAt.Sub(parsed.IssuedAt.Time); got != tt.want {
+				t.Errorf("lifetime = %v, want %v", got, tt.want)
+			}
+			if parsed.Actor == nil || parsed.Actor.Subject != "operator-1" || parsed.Actor.TenantID != "tenant-ops" {
+				t.Errorf("act = %+v, want operator-1@tenant-ops", parsed.Actor)
+			}
+			if parsed.Subject != "user-1" || parsed.TenantID != "tenant-a" || parsed.TokenUse != TokenUseAccess {
+				t.Errorf("sub/tid/use = %q/%q/%q, want user-1/tenant-a/access", parsed.Subject, parsed.TenantID, parsed.TokenUse)
+			}
+			// Sesi operator, bukan sesi target, sehingga logout operator mengakhiri impersonasi.
+			if parsed.SessionID != "sid-op" {
+				t.Errorf("sid = %q, want operator session sid-op", parsed.SessionID)
+			}
+			// MFA operator maupun amr target tidak terbawa.
+			if parsed.HasMFA() || parsed.MFA || len(parsed.AMR) != 0 {
+				t.Errorf("impersonation token carries MFA: amr=%v mfa=%v", parsed.AMR, parsed.MFA)
+			}
+			if !claims.IsImpersonated() || claims.ID != parsed.ID {
+				t.Errorf("returned claims = %+v, want the signed claims", claims)
+			}
+		})
+	}
+}
+
+func impersonatedClaims(role string) *PrismClaims {
+	claims := &PrismClaims{TenantID: "tenant-a", Role: role, Actor: &ActorClaim{Subject: "operator-1", TenantID: "tenant-ops"}}
+	claims.Subject = "user-1"
+	claims.ID = "jti-imp"
+	return claims
+}
+
+// serveWithClaims menjalankan satu request dengan claims yang sudah terpasang, seperti setelah
+// JWTMiddleware, lalu handlers, dan mengembalikan status HTTP-nya.
+func serveWithClaims(claims *PrismClaims, handlers ...gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	chain := []gin.HandlerFunc{func(c *gin.Context) {
+		if claims != nil {
+			setGinIdentity(c, NewIdentityFromClaims(claims))
+		}
+	}}
+	chain = append(chain, handlers...)
+	chain = append(chain, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.POST("/invoices/:id", chain...)
+	req := httptest.NewRequest(http.MethodPost, "/invoices/42", nil)
+	req.RemoteAddr = "10.0.0.7:1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestImpersonationRestrictions(t *testing.T) {
+	m := NewRBACMiddlewareWithSource(NewMemoryPermissionSource(RolePermissions{"admin": {"*"}}), time.Minute,
+		WithImpersonationRestrictions("billing", "user:*:delete"))
+	defer m.Close()
+	normal := &PrismClaims{TenantID: "tenant-a", Role: "admin"}
+	impersonated := impersonatedClaims("admin")
+
+	tests := []struct {
+		name       string
+		permission string
+		blocked    bool
+	}{
+		{"exact", "billing", true},
+		{"child of blocked permission", "billing:refund", true},
+		{"wildcard segment", "user:42:delete", true},
+		{"child of wildcard", "user:42:delete:all", true},
+		{"unrelated", "invoice:read", false},
+		{"sibling of wildcard", "user:42:read", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if authErr := m.checkPermission(context.Background(), normal, tt.permission); authErr != nil {
+				t.Errorf("normal token denied: %v", authErr)
+			}
+			authErr := m.checkPermission(context.Background(), impersonated, tt.permission)
+			if got := authErr != nil; got != tt.blocked {
+				t.Errorf("impersonated blocked = %v, want %v (%v)", got, tt.blocked, authErr)
+			}
+		})
+	}
+
+	handlerTests := []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{"RequirePermission", m.RequirePermission("billing:refund")},
+		{"RequirePolicy", m.RequirePolicy("billing:refund || role:admin && billing:refund")},
+		{"RequireAny", m.RequireAny("billing:refund", "user:1:delete")},
+		{"RequireAll", m.RequireAll("invoice:read", "billing:refund")},
+	}
+	for _, tt := range handlerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveWithClaims(normal, tt.handler); got != http.StatusNoContent {
+				t.Errorf("normal token status = %d, want %d", got, http.StatusNoContent)
+			}
+			if got := serveWithClaims(impersonated, tt.handler); got != http.StatusForbidden {
+				t.Errorf("impersonated status = %d, want %d", got, http.StatusForbidden)
+			}
+		})
+	}
+
+	// Atom role: tidak diblokir; hanya izin yang diblokir.
+	if got := serveWithClaims(impersonated, m.RequirePolicy("role:admin")); got != http.StatusNoContent {
+		t.Errorf("role policy while impersonating = %d, want %d", got, http.StatusNoContent)
+	}
+
+	interceptor := m.UnaryServerInterceptor(map[string]string{"/billing.v1.BillingService/Refund": "billing:refund"})
+	info := &grpc.UnaryServerInfo{FullMethod: "/billing.v1.BillingService/Refund"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+	if _, err := interceptor(ContextWithClaims(context.Background(), normal), nil, info, handler); err != nil {
+		t.Errorf("gRPC normal token: %v", err)
+	}
+	if _, err := interceptor(ContextWithClaims(context.Background(), impersonated), nil, info, handler); err == nil {
+		t.Error("gRPC impersonated token allowed a blocked permission")
+	}
+
+	// Tanpa opsi, token impersonasi diperlakukan seperti token target.
+	unrestricted := NewRBACMiddlewareWithSource(NewMemoryPermissionSource(RolePermissions{"admin": {"*"}}), time.Minute)
+	defer unrestricted.Close()
+	if authErr := unrestricted.checkPermission(context.Background(), impersonated, "billing:refund"); authErr != nil {
+		t.Errorf("unrestricted middleware denied impersonation: %v", authErr)
+	}
+}
+
+func TestDenyImpersonation(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims *PrismClaims
+		want   int
+	}{
+		{"normal token", &PrismClaims{TenantID: "tenant-a", Role: "staff"}, http.StatusNoContent},
+		{"impersonated token", impersonatedClaims("staff"), http.StatusForbidden},
+		{"no claims", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveWithClaims(tt.claims, DenyImpersonation()); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// auditRecorder adalah ImpersonationAuditSink yang menyimpan event untuk diperiksa.
+type auditRecorder struct {
+	events []ImpersonationAuditEvent
+}
+
+func (r *auditRecorder) sink(_ context.Context, event ImpersonationAuditEvent) {
+	r.events = append(r.events, event)
+}
+
+func (r *auditRecorder) check(t *testing.T, method, path string, status int) {
+	t.Helper()
+	if len(r.events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(r.events))
+	}
+	event := r.events[0]
+	if event.OperatorID != "operator-1" || event.OperatorTenantID != "tenant-ops" ||
+		event.UserID != "user-1" || event.TenantID != "tenant-a" || event.TokenID != "jti-imp" {
+		t.Errorf("audit identity = %+v", event)
+	}
+	if event.Method != method || event.Path != path || event.Status != status {
+		t.Errorf("audit method/path/status = %q/%q/%d, want %q/%q/%d", event.Method, event.Path, event.Status, method, path, status)
+	}
+}
+
+func TestImpersonationAudit(t *testing.T) {
+	deny := func(c *gin.Context) { c.AbortWithStatus(http.StatusForbidden) }
+	tests := []struct {
+		name       string
+		claims     *PrismClaims
+		handlers   []gin.HandlerFunc
+		wantEvents int
+		wantStatus int
+	}{
+		{"impersonated request", impersonatedClaims("staff"), nil, 1, http.StatusNoContent},
+		// Request yang ditolak middleware berikutnya tetap tercatat.
+		{"denied impersonated request", impersonatedClaims("staff"), []gin.HandlerFunc{deny}, 1, http.StatusForbidden},
+		{"normal request", &PrismClaims{TenantID: "tenant-a", Role: "staff"}, nil, 0, http.StatusNoContent},
+		{"no claims", nil, nil, 0, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &auditRecorder{}
+			handlers := append([]gin.HandlerFunc{ImpersonationAudit(recorder.sink)}, tt.handlers...)
+			serveWithClaims(tt.claims, handlers...)
+			if len(recorder.events) != tt.wantEvents {
+				t.Fatalf("audit events = %d, want %d", len(recorder.events), tt.wantEvents)
+			}
+			if tt.wantEvents == 1 {
+				recorder.check(t, http.MethodPost, "/invoices/42", tt.wantStatus)
+				if recorder.events[0].ClientIP != "10.0.0.7" {
+					t.Errorf("audit client IP = %q, want 10.0.0.7", recorder.events[0].ClientIP)
+				}
+			}
+		})
+	}
+}
+
+// fakeServerStream adalah grpc.ServerStream dengan context tertentu.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestImpersonationAuditInterceptors(t *testing.T) {
+	const method = "/billing.v1.BillingService/Refund"
+	denied := status.Error(codes.PermissionDenied, "denied")
+	impersonated := ContextWithClaims(context.Background(), impersonatedClaims("staff"))
+	normal := ContextWithClaims(context.Background(), &PrismClaims{TenantID: "tenant-a", Role: "staff"})
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		err        error
+		wantEvents int
+		wantStatus codes.Code
+	}{
+		{"impersonated ok", impersonated, nil, 1, codes.OK},
+		{"impersonated error", impersonated, denied, 1, codes.PermissionDenied},
+		{"normal", normal, nil, 0, codes.OK},
+		{"no claims", context.Background(), nil, 0, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unary := &auditRecorder{}
+			_, err := ImpersonationAuditUnaryInterceptor(unary.sink)(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+				func(context.Context, interface{}) (interface{}, error) { return nil, tt.err })
+			if err != tt.err {
+				t.Errorf("unary error = %v, want %v", err, tt.err)
+			}
+
+			stream := &auditRecorder{}
+			err = ImpersonationAuditStreamInterceptor(stream.sink)(nil, &fakeServerStream{ctx: tt.ctx}, &grpc.StreamServerInfo{FullMethod: method},
+				func(interface{}, grpc.ServerStream) error { return tt.err })
+			if err != tt.err {
+				t.Errorf("stream error = %v, want %v", err, tt.err)
+			}
+
+			for name, recorder := range map[string]*auditRecorder{"unary": unary, "stream": stream} {
+				if len(recorder.events) != tt.wantEvents {
+					t.Errorf("%s audit events = %d, want %d", name, len(recorder.events), tt.wantEvents)
+					continue
+				}
+				if tt.wantEvents == 1 {
+					recorder.check(t, "grpc", method, int(tt.wantStatus))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrInvalidRefreshToken dikembalikan untuk refresh token yang rusak, kedaluwarsa, atau familinya sudah dicabut.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused dikembalikan ketika refresh token yang sudah dirotasi dipakai lagi.
+	// Seluruh famili token dicabut karena kemungkinan besar token telah dicuri.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// rotateRefreshScript melakukan compare-and-swap atomik pada famili refresh token.
+// Return 1 = berhasil dirotasi, 0 = reuse terdeteksi (famili dihapus), -1 = famili tidak ada.
+var rotateRefreshScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// IssuerConfig mengatur parameter token yang dicetak oleh Issuer.
+type IssuerConfig struct {
+	Issuer     string
+	Audience   []string
+	AccessTTL  time.Duration // Default DefaultAccessTokenTTL
+	RefreshTTL time.Duration // Default DefaultRefreshTokenTTL
+	// KeyPrefix untuk key famili refresh token di Redis. Default "prism:refresh:family:".
+	KeyPrefix string
+	// SubjectLoader (opsional) memuat ulang data subjek saat refresh agar perubahan role
+	// langsung berlaku. Jika nil, data dari refresh token lama dipakai ulang.
+	SubjectLoader func(ctx context.Context, userID, tenantID string) (TokenSubject, error)
+	// Revocation (opsional) diperiksa saat refresh, misalnya session.Store, agar sesi yang
+	// sudah diakhiri tidak bisa mencetak token baru.
+	Revocation RevocationChecker
+}
+
+// TokenSubject adalah data identitas yang dimasukkan ke dalam token.
+type TokenSubject struct {
+	UserID      string
+	TenantID    string
+	Role        string
+	Roles       []string
+	Permissions []string
+	Scopes      []string
+	SessionID   string
+	// AMR adalah metode otentikasi saat login, misalnya []string{AMRPassword, AMROneTimePassword}.
+	AMR []string
+	// Attributes adalah atribut subjek untuk ABAC (claim attrs).
+	Attributes map[string]interface{}
+	// Actor diisi untuk token impersonasi; lihat IssueImpersonationToken.
+	Actor *ActorClaim
+}
+
+// TokenPair adalah pasangan token akses dan refresh hasil IssuePair/Refresh.
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int64     `json:"expires_in"`
+	AccessExpiresAt  time.Time `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
+}
+
+// refreshClaims adalah claim refresh token: PrismClaims ditambah ID famili rotasi.
+type refreshClaims struct {
+	PrismClaims
+	FamilyID string `json:"fam"`
+}
+
+// Issuer mencetak token akses dan refresh dengan layout claim yang sama dengan yang
+// divalidasi JWTMiddleware (sub, tid, jti, role).
+type Issuer struct {
+	keys        SigningKeySet
+	redisClient *redis.Client
+	cfg         IssuerConfig
+	now         func() time.Time
+}
+
+// NewIssuer membuat Issuer. redisClient boleh nil jika hanya token akses yang dicetak;
+// IssuePair dan Refresh membutuhkan Redis untuk rotasi dan deteksi reuse.
+func NewIssuer(keys SigningKeySet, redisClient *redis.Client, cfg IssuerConfig) (*Issuer, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("issuer requires a signing key set")
+	}
+	if cfg.AccessTTL <= 0 {
+		cfg.AccessTTL = DefaultAccessTokenTTL
+	}
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = DefaultRefreshTokenTTL
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "prism:refresh:family:"
+	}
+	return &Issuer{keys: keys, redisClient: redisClient, cfg: cfg, now: time.Now}, nil
+}
+
+// MaxTokenLifetime mengembalikan umur token terpanjang yang bisa dicetak Issuer ini. Gunakan
+// sebagai MaxTokenLifetime store pencabutan agar marker RevokeAllForUser/RevokeAllForTenant
+// tidak kedaluwarsa sebelum refresh token yang dicabutnya.
+func (i *Issuer) MaxTokenLifetime() time.Duration {
+	return max(i.cfg.AccessTTL, i.cfg.RefreshTTL, MaxImpersonationTTL)
+}
+
+func (i *Issuer) sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	key, err := i.keys.SigningKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get signing key: %w", err)
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+func (i *Issuer) baseClaims(subject TokenSubject, use string, issuedAt time.Time, ttl time.Duration) PrismClaims {
+	claims := PrismClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.cfg.Issuer,
+			Subject:   subject.UserID,
+			Audience:  i.cfg.Audience,
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(issuedAt),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ID:        uuid.NewString(),
+		},
+		TenantID:    subject.TenantID,
+		Role:        subject.Role,
+		Roles:       subject.Roles,
+		Permissions: subject.Permissions,
+		SessionID:   subject.SessionID,
+		Scopes:      subject.Scopes,
+		TokenUse:    use,
+		AMR:         subject.AMR,
+		Attributes:  subject.Attributes,
+		Actor:       subject.Actor,
+	}
+	claims.MFA = claims.HasMFA()
+	return claims
+}
+
+// IssueAccessToken mencetak satu token akses tanpa refresh token, misalnya untuk job internal.
+func (i *Issuer) IssueAccessToken(ctx context.Context, subject TokenSubject) (string, *PrismClaims, error) {
+	if subject.UserID == "" || subject.TenantID == "" {
+		return "", nil, fmt.Errorf("token subject requires user ID and tenant ID")
+	}
+	claims := i.baseClaims(subject, TokenUseAccess, i.now(), i.cfg.AccessTTL)
+	signed, err := i.sign(ctx, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &claims, nil
+}
+
+// IssuePair mencetak token akses dan refresh token yang memulai famili rotasi baru.
+func (i *Issuer) IssuePair(ctx context.Context, subject TokenSubject) (*TokenPair, error) {
+	return i.issuePair(ctx, subject, uuid.NewString(), "")
+}
+
+// issuePair mencetak pasangan token. Jika previousJTI kosong, famili baru dibuat;
+// jika tidak, famili dirotasi secara atomik dari previousJTI ke refresh token baru.
+func (i *Issuer) issuePair(ctx context.Context, subject TokenSubject, familyID, previousJTI string) (*TokenPair, error) {
+	if i.redisClient == nil {
+		return nil, fmt.Errorf("refresh tokens require a Redis client")
+	}
+
+	access, accessClaims, err := i.IssueAccessToken(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	now := i.now()
+	refresh := refreshClaims{
+		PrismClaims: i.baseClaims(subject, TokenUseRefresh, now, i.cfg.RefreshTTL),
+		FamilyID:    familyID,
+	}
+	refreshToken, err := i.sign(ctx, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	familyKey := i.cfg.KeyPrefix + familyID
+	if previousJTI == "" {
+		if err := i.redisClient.Set(ctx, familyKey, refresh.ID, i.cfg.RefreshTTL).Err(); err != nil {
+			return nil, fmt.Errorf("failed to store refresh token family: %w", err)
+		}
+	} else {
+		result, err := rotateRefreshScript.Run(ctx, i.redisClient, []string{familyKey},
+			previousJTI, refresh.ID, i.cfg.RefreshTTL.Milliseconds()).Int()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		switch result {
+		case 0:
+			return nil, ErrRefreshTokenReused
+		case -1:
+			return nil, ErrInvalidRefreshToken
+		}
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(i.cfg.AccessTTL.Seconds()),
+		AccessExpiresAt:  accessClaims.ExpiresAt.Time,
+		RefreshExpiresAt: refresh.ExpiresAt.Time,
+	}, nil
+}
+
+// Refresh memvalidasi refresh token, merotasinya, dan mengembalikan pasangan token baru.
+// Refresh token lama tidak bisa dipakai lagi; jika dipakai, seluruh famili dicabut
+// dan ErrRefreshTokenReused dikembalikan.
+func (i *Issuer) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	parserOpts := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if i.cfg.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(i.cfg.Issuer))
+	}
+
+	claims := &refreshClaims{}
+	_, err := jwt.ParseWithClaims(refreshToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return i.keys.VerificationKey(ctx, token)
+	}, parserOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRefreshToken, err)
+	}
+	if claims.TokenUse != TokenUseRefresh || claims.FamilyID == "" || claims.ID == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	if i.cfg.Revocation != nil {
+		revoked, err := i.cfg.Revocation.IsRevoked(ctx, &claims.PrismClaims)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check refresh token revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrInvalidRefreshToken
+		}
+	}
+
+	subject := TokenSubject{
+		UserID:      claims.Subject,
+		TenantID:    claims.TenantID,
+		Role:        claims.Role,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		Scopes:      claims.Scopes,
+		SessionID:   claims.SessionID,
+		AMR:         claims.AMR,
+		Attributes:  claims.Attributes,
+		Actor:       claims.Actor,
+	}
+	if i.cfg.SubjectLoader != nil {
+		subject, err = i.cfg.SubjectLoader(ctx, claims.Subject, claims.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reload token subject: %w", err)
+		}
+		// Sesi, metode login, dan aktor adalah fakta login, bukan data pengguna; pertahankan
+		// dari refresh token agar Terminate dan batas sesi tetap berlaku setelah refresh.
+		subject.SessionID = claims.SessionID
+		subject.AMR = claims.AMR
+		subject.Actor = claims.Actor
+	}
+
+	return i.issuePair(ctx, subject, claims.FamilyID, claims.ID)
+}
+
+// RevokeRefreshFamily mencabut semua refresh token dalam famili dari refresh token ini,
+// misalnya saat logout. Token yang tidak valid diabaikan.
+func (i *Issuer) RevokeRefreshFamily(ctx context.Context, refreshToken string) error {
+	if i.redisClient == nil {
+		return fmt.Errorf("refresh tokens require a Redis client")
+	}
+	claims := &refreshClaims{}
+	_, err := jwt.ParseWithClaims(refreshToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return i.keys.VerificationKey(ctx, token)
+	}, jwt.WithoutClaimsValidation())
+	if err != nil || claims.FamilyID == "" {
+		return nil
+	}
+	return i.redisClient.Del(ctx, i.cfg.KeyPrefix+claims.FamilyID).Err()
+}
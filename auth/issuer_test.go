@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+var testHMACKeys = HMACKeyProvider{Secret: []byte("issuer-test-secret-0123456789abcdef")}
+
+func newTestIssuer(t *testing.T, cfg IssuerConfig) (*Issuer, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	issuer, err := NewIssuer(testHMACKeys, client, cfg)
+	if err != nil {
+		t.Fatalf("NewIssuer: %v", err)
+	}
+	return issuer, mr
+}
+
+func parseTestToken(t *testing.T, token string) *refreshClaims {
+	t.Helper()
+	claims := &refreshClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return testHMACKeys.VerificationKey(context.Background(), token)
+	}); err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	return claims
+}
+
+func TestIssuerRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	subject := TokenSubject{UserID: "user-1", TenantID: "tenant-a", Role: "staff", SessionID: "sid-1"}
+
+	tests := []struct {
+		name string
+		// run memakai refresh token pertama (first) dan hasil rotasinya (second).
+		run     func(i *Issuer, first, second string) error
+		wantErr error
+	}{
+		{
+			name:    "rotated token can be refreshed",
+			run:     func(i *Issuer, _, second string) error { _, err := i.Refresh(ctx, second); return err },
+			wantErr: nil,
+		},
+		{
+			name:    "reusing rotated token is detected",
+			run:     func(i *Issuer, first, _ string) error { _, err := i.Refresh(ctx, first); return err },
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name: "reuse revokes the whole family",
+			run: func(i *Issuer, first, second string) error {
+				if _, err := i.Refresh(ctx, first); !errors.Is(err, ErrRefreshTokenReused) {
+					return err
+				}
+				_, err := i.Refresh(ctx, second)
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "revoked family is rejected",
+			run: func(i *Issuer, _, second string) error {
+				if err := i.RevokeRefreshFamily(ctx, second); err != nil {
+					return err
+				}
+				_, err := i.Refresh(ctx, second)
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "access token is not a refresh token",
+			run: func(i *Issuer, _, _ string) error {
+				access, _, err := i.IssueAccessToken(ctx, subject)
+				if err != nil {
+					return err
+				}
+				_, err = i.Refresh(ctx, access)
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "garbage token",
+			run:     func(i *Issuer, _, _ string) error { _, err := i.Refresh(ctx, "not-a-token"); return err },
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer, _ := newTestIssuer(t, IssuerConfig{Issuer: "prism-auth"})
+			pair, err := issuer.IssuePair(ctx, subject)
+			if err != nil {
+				t.Fatalf("IssuePair: %v", err)
+			}
+			rotated, err := issuer.Refresh(ctx, pair.RefreshToken)
+			if err != nil {
+				t.Fatalf("first Refresh: %v", err)
+			}
+			err = tt.run(issuer, pair.RefreshToken, rotated.RefreshToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIssuerRefreshKeepsLoginFacts(t *testing.T) {
+	ctx := context.Background()
+	issuer, _ := newTestIssuer(t, IssuerConfig{
+		// SubjectLoader hanya tahu data pengguna; sid, amr, dan act harus tetap dari refresh token.
+		SubjectLoader: func(_ context.Context, userID, tenantID string) (TokenSubject, error) {
+			return TokenSubject{UserID: userID, TenantID: tenantID, Role: "manager"}, nil
+		},
+	})
+	pair, err := issuer.IssuePair(ctx, TokenSubject{
+		UserID:    "user-1",
+		TenantID:  "tenant-a",
+		Role:      "staff",
+		SessionID: "sid-1",
+		AMR:       []string{AMRPassword, AMROneTimePassword},
+	})
+	if err != nil {
+		t.Fatalf("IssuePair: %v", err)
+	}
+
+	refresh := pair.RefreshToken
+	for round := 1; round <= 2; round++ {
+		rotated, err := issuer.Refresh(ctx, refresh)
+		if err != nil {
+			t.Fatalf("Refresh round %d: %v", round, err)
+		}
+		for name, token := range map[string]string{"access": rotated.AccessToken, "refresh": rotated.RefreshToken} {
+			claims := parseTestToken(t, token)
+			if claims.SessionID != "sid-1" {
+				t.Errorf("round %d %s token sid = %q, want %q", round, name, claims.SessionID, "sid-1")
+			}
+			if !claims.HasMFA() {
+				t.Errorf("round %d %s token lost amr: %v", round, name, claims.AMR)
+			}
+			if claims.Role != "manager" {
+				t.Errorf("round %d %s token role = %q, want reloaded %q", round, name, claims.Role, "manager")
+			}
+		}
+		refresh = rotated.RefreshToken
+	}
+}
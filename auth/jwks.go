@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/client"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK adalah representasi satu JSON Web Key (RFC 7517). Hanya field untuk kunci publik
+// yang didukung; kunci privat tidak pernah dibaca dari JWKS.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC & OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS adalah dokumen JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// publicKey adalah kunci yang sudah di-decode beserta algoritma yang diizinkan (opsional).
+type publicKey struct {
+	key interface{}
+	alg string
+}
+
+// minRSAKeyBits adalah ukuran modulus RSA minimum yang diterima dari JWKS.
+const minRSAKeyBits = 2048
+
+// jwksOnDemandTimeout membatasi refresh yang dipicu kid tidak dikenal.
+const jwksOnDemandTimeout = 5 * time.Second
+
+// ParseJWKS mem-parse dokumen JWKS menjadi map kid -> kunci publik.
+// Kunci dengan `use` selain "sig" dilewati. Dokumen dengan kid ganda, termasuk lebih dari
+// satu kunci tanpa kid, ditolak karena kunci tersebut akan saling menimpa.
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(keys))
+	for kid, k := range keys {
+		result[kid] = k.key
+	}
+	return result, nil
+}
+
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key at index %d (kid '%s'): %w", i, jwk.Kid, err)
+		}
+		if _, dup := keys[jwk.Kid]; dup {
+			if jwk.Kid == "" {
+				return nil, fmt.Errorf("JWKS document contains more than one signing key without a kid")
+			}
+			return nil, fmt.Errorf("JWKS document contains duplicate kid '%s'", jwk.Kid)
+		}
+		keys[jwk.Kid] = publicKey{key: key, alg: jwk.Alg}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS document contains no signing keys")
+	}
+	return keys, nil
+}
+
+// PublicKey men-decode JWK menjadi *rsa.PublicKey, *ecdsa.PublicKey, atau ed25519.PublicKey.
+func (k JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeB64(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decodeB64(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key is %d bits, minimum is %d", modulus.BitLen(), minRSAKeyBits)
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exp.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve '%s'", k.Crv)
+		}
+		x, err := decodeB64(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := decodeB64(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid EC coordinate length for curve '%s'", k.Crv)
+		}
+		// Validasi bahwa titik berada di kurva menggunakan crypto/ecdh.
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve '%s'", k.Crv)
+		}
+		x, err := decodeB64(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Ed25519 key: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", k.Kty)
+	}
+}
+
+// NewJWK membangun JWK dari kunci publik, dipakai oleh auth service untuk mempublikasikan
+// JWKS-nya. Kunci privat ditolak agar tidak pernah bocor ke dokumen publik.
+func NewJWK(kid, alg string, key interface{}) (JWK, error) {
+	jwk := JWK{Kid: kid, Alg: alg, Use: "sig"}
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return jwk, nil
+}
+
+func decodeB64(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty value")
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// JWKSSource adalah asal dokumen JWKS (file, endpoint HTTP, Vault, dll).
+type JWKSSource interface {
+	FetchJWKS(ctx context.Context) ([]byte, error)
+}
+
+// FileJWKSSource membaca JWKS dari file lokal, misalnya yang di-mount dari secret Kubernetes.
+type FileJWKSSource struct {
+	Path string
+}
+
+func (s FileJWKSSource) FetchJWKS(_ context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file '%s': %w", s.Path, err)
+	}
+	return data, nil
+}
+
+// HTTPJWKSSource mengambil JWKS dari endpoint HTTP, misalnya /.well-known/jwks.json milik auth service.
+type HTTPJWKSSource struct {
+	URL    string
+	Client *http.Client // Opsional; default memakai client dengan timeout 10 detik.
+}
+
+func (s HTTPJWKSSource) FetchJWKS(ctx context.Context) ([]byte, error) {
+	httpClient := s.Client
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS from '%s': %w", s.URL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d fetching JWKS from '%s'", resp.StatusCode, s.URL)
+	}
+	// Batasi ukuran body agar endpoint yang salah konfigurasi tidak menghabiskan memori.
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// VaultJWKSSource membaca dokumen JWKS yang disimpan sebagai string di Vault KV v2.
+type VaultJWKSSource struct {
+	Client *client.VaultClient
+	Path   string // misal "secret/data/prism/jwks"
+	Key    string // misal "jwks"
+}
+
+func (s VaultJWKSSource) FetchJWKS(_ context.Context) ([]byte, error) {
+	value, err := s.Client.ReadSecret(s.Path, s.Key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// JWKSKeyProvider adalah KeyProvider yang memuat kunci publik dari JWKSSource,
+// menyimpannya di memori, dan me-refresh secara berkala di background.
+// Jika `kid` tidak dikenal, provider mencoba refresh segera (dibatasi MinRefreshInterval)
+// sehingga rotasi kunci di auth service langsung terlihat.
+type JWKSKeyProvider struct {
+	source             JWKSSource
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	mu   sync.RWMutex
+	keys map[string]publicKey
+	// lastAttempt dicatat sebelum setiap fetch, berhasil atau tidak, untuk membatasi refresh on-demand.
+	lastAttempt time.Time
+
+	refreshMu sync.Mutex
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+const (
+	DefaultJWKSRefreshInterval    = 15 * time.Minute
+	DefaultJWKSMinRefreshInterval = 30 * time.Second
+)
+
+// NewJWKSKeyProvider memuat JWKS untuk pertama kali dan memulai refresh background.
+// refreshInterval <= 0 memakai DefaultJWKSRefreshInterval. Panggil Close untuk menghentikan refresh.
+func NewJWKSKeyProvider(ctx context.Context, source JWKSSource, refreshInterval time.Duration) (*JWKSKeyProvider, error) {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultJWKSRefreshInterval
+	}
+	p := &JWKSKeyProvider{
+		source:             source,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: DefaultJWKSMinRefreshInterval,
+		stop:               make(chan struct{}),
+	}
+	if err := p.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("initial JWKS load failed: %w", err)
+	}
+
+	go p.refreshLoop()
+	return p, nil
+}
+
+// Refresh mengambil ulang JWKS dari source. Jika gagal, kunci lama tetap dipakai.
+func (p *JWKSKeyProvider) Refresh(ctx context.Context) error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	return p.refreshLocked(ctx)
+}
+
+func (p *JWKSKeyProvider) refreshLocked(ctx context.Context) error {
+	p.mu.Lock()
+	p.lastAttempt = time.Now()
+	p.mu.Unlock()
+
+	data, err := p.source.FetchJWKS(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *JWKSKeyProvider) refreshLoop() {
+	ticker := time.NewTicker(p.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := p.Refresh(ctx); err != nil {
+				log.Printf("Peringatan: gagal me-refresh JWKS, tetap memakai kunci lama: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Close menghentikan refresh background.
+func (p *JWKSKeyProvider) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+func (p *JWKSKeyProvider) lookup(kid string) (publicKey, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if kid == "" {
+		// Token tanpa kid hanya diterima jika set hanya berisi satu kunci.
+		if len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k, true
+			}
+		}
+		return publicKey{}, false
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+func (p *JWKSKeyProvider) VerificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid := keyID(token)
+	k, ok := p.lookup(kid)
+	// Kid tidak dikenal: kemungkinan kunci baru saja dirotasi. Refresh sekali, tapi jangan
+	// lebih sering dari minRefreshInterval sejak percobaan terakhir (juga yang gagal) agar
+	// kid karangan tidak bisa memicu fetch berulang saat endpoint JWKS mati. Fetch memakai
+	// context terpisah dengan batas waktu sendiri sehingga tidak bergantung pada request.
+	// Jika refresh lain sedang berjalan (bisa sampai 30 detik untuk refresh background),
+	// request langsung ditolak alih-alih menunggu.
+	if !ok && kid != "" && p.refreshMu.TryLock() {
+		p.mu.RLock()
+		due := time.Since(p.lastAttempt) >= p.minRefreshInterval
+		p.mu.RUnlock()
+		if due {
+			refreshCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksOnDemandTimeout)
+			if err := p.refreshLocked(refreshCtx); err != nil {
+				log.Printf("Peringatan: gagal me-refresh JWKS untuk kid '%s': %v", kid, err)
+			}
+			cancel()
+		}
+		p.refreshMu.Unlock()
+		k, ok = p.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownKeyID, kid)
+	}
+
+	if k.alg != "" && k.alg != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Method.Alg())
+	}
+	if err := checkMethodForKey(token.Method, k.key); err != nil {
+		return nil, err
+	}
+	return k.key, nil
+}
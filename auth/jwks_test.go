@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	testRSAKeyOnce sync.Once
+	testRSAKey     *rsa.PrivateKey
+)
+
+// rsaTestKey membuat satu kunci RSA 2048-bit untuk seluruh test di paket ini.
+func rsaTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	testRSAKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		testRSAKey = key
+	})
+	return testRSAKey
+}
+
+func mustJWK(t *testing.T, kid, alg string, key interface{}) JWK {
+	t.Helper()
+	jwk, err := NewJWK(kid, alg, key)
+	if err != nil {
+		t.Fatalf("NewJWK: %v", err)
+	}
+	return jwk
+}
+
+func jwksDocument(t *testing.T, keys ...JWK) []byte {
+	t.Helper()
+	data, err := json.Marshal(JWKS{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey := rsaTestKey(t)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	weakRSA, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	rsaJWK := mustJWK(t, "rsa-1", "RS256", &rsaKey.PublicKey)
+	ecJWK := mustJWK(t, "ec-1", "ES256", &ecKey.PublicKey)
+	edJWK := mustJWK(t, "ed-1", "EdDSA", edPub)
+
+	encJWK := mustJWK(t, "enc-1", "", &rsaKey.PublicKey)
+	encJWK.Use = "enc"
+	offCurve := ecJWK
+	offCurve.Kid = "ec-bad"
+	offCurve.Y = ecJWK.X
+	shortEC := ecJWK
+	shortEC.Kid = "ec-short"
+	shortEC.X = base64.RawURLEncoding.EncodeToString([]byte{1, 2, 3})
+	badExponent := rsaJWK
+	badExponent.Kid = "rsa-exp"
+	badExponent.E = base64.RawURLEncoding.EncodeToString([]byte{1})
+	noKid1 := mustJWK(t, "", "", &rsaKey.PublicKey)
+	noKid2 := mustJWK(t, "", "", edPub)
+
+	tests := []struct {
+		name     string
+		doc      []byte
+		wantKids []string
+		wantErr  string
+	}{
+		{"rsa, ec and ed25519", jwksDocument(t, rsaJWK, ecJWK, edJWK), []string{"rsa-1", "ec-1", "ed-1"}, ""},
+		{"encryption keys skipped", jwksDocument(t, rsaJWK, encJWK), []string{"rsa-1"}, ""},
+		{"only encryption keys", jwksDocument(t, encJWK), nil, "no signing keys"},
+		{"empty set", []byte(`{"keys":[]}`), nil, "no signing keys"},
+		{"invalid json", []byte(`{"keys":`), nil, "invalid JWKS document"},
+		{"duplicate kid", jwksDocument(t, rsaJWK, mustJWK(t, "rsa-1", "EdDSA", edPub)), nil, "duplicate kid 'rsa-1'"},
+		{"two keys without kid", jwksDocument(t, noKid1, noKid2), nil, "more than one signing key without a kid"},
+		{"weak rsa key", jwksDocument(t, mustJWK(t, "weak", "RS256", &weakRSA.PublicKey)), nil, "1024 bits, minimum is 2048"},
+		{"rsa exponent too small", jwksDocument(t, badExponent), nil, "invalid RSA exponent"},
+		{"ec point off curve", jwksDocument(t, offCurve), nil, "invalid EC point"},
+		{"ec coordinate length", jwksDocument(t, shortEC), nil, "invalid EC coordinate length"},
+		{"unsupported curve", jwksDocument(t, JWK{Kty: "EC", Kid: "k", Crv: "P-192", X: "AA", Y: "AA"}), nil, "unsupported EC curve"},
+		{"unsupported okp curve", jwksDocument(t, JWK{Kty: "OKP", Kid: "k", Crv: "X25519", X: "AA"}), nil, "unsupported OKP curve"},
+		{"unsupported key type", jwksDocument(t, JWK{Kty: "oct", Kid: "k"}), nil, "unsupported key type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseJWKS(tt.doc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseJWKS error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJWKS: %v", err)
+			}
+			if len(keys) != len(tt.wantKids) {
+				t.Errorf("ParseJWKS returned %d keys, want %d", len(keys), len(tt.wantKids))
+			}
+			for _, kid := range tt.wantKids {
+				if _, ok := keys[kid]; !ok {
+					t.Errorf("kid %q missing from %v", kid, keys)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckMethodForKey(t *testing.T) {
+	rsaKey := rsaTestKey(t)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		key    interface{}
+		wantOK bool
+	}{
+		{"hmac secret", jwt.SigningMethodHS256, []byte("secret"), true},
+		{"rsa", jwt.SigningMethodRS256, &rsaKey.PublicKey, true},
+		{"rsa-pss", jwt.SigningMethodPS256, &rsaKey.PublicKey, true},
+		{"ecdsa", jwt.SigningMethodES256, &ecKey.PublicKey, true},
+		{"ed25519", jwt.SigningMethodEdDSA, edPub, true},
+		// Algorithm confusion: token HS256 yang ditandatangani dengan kunci publik sebagai secret.
+		{"hs256 with rsa public key", jwt.SigningMethodHS256, &rsaKey.PublicKey, false},
+		{"hs256 with ecdsa public key", jwt.SigningMethodHS256, &ecKey.PublicKey, false},
+		{"hs256 with ed25519 public key", jwt.SigningMethodHS256, edPub, false},
+		{"rs256 with hmac secret", jwt.SigningMethodRS256, []byte("secret"), false},
+		{"es256 with rsa key", jwt.SigningMethodES256, &rsaKey.PublicKey, false},
+		{"eddsa with ecdsa key", jwt.SigningMethodEdDSA, &ecKey.PublicKey, false},
+		{"none", jwt.SigningMethodNone, []byte("secret"), false},
+		{"unsupported key type", jwt.SigningMethodRS256, "not-a-key", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMethodForKey(tt.method, tt.key)
+			if (err == nil) != tt.wantOK {
+				t.Errorf("checkMethodForKey(%s, %T) error = %v, wantOK %v", tt.method.Alg(), tt.key, err, tt.wantOK)
+			}
+		})
+	}
+}
+
+// stubJWKSSource mengembalikan dokumen saat ini dan menghitung jumlah fetch. Jika block tidak
+// nil, fetch menunggu sampai channel ditutup.
+type stubJWKSSource struct {
+	mu      sync.Mutex
+	doc     []byte
+	fetches atomic.Int32
+	block   chan struct{}
+	started chan struct{}
+}
+
+func (s *stubJWKSSource) FetchJWKS(ctx context.Context) ([]byte, error) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	block, started, doc := s.block, s.started, s.doc
+	s.mu.Unlock()
+	if block != nil {
+		close(started)
+		select {
+		case <-block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return doc, nil
+}
+
+func (s *stubJWKSSource) set(doc []byte) {
+	s.mu.Lock()
+	s.doc = doc
+	s.mu.Unlock()
+}
+
+func TestJWKSKeyProviderVerificationKey(t *testing.T) {
+	rsaKey := rsaTestKey(t)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	source := &stubJWKSSource{doc: jwksDocument(t, mustJWK(t, "rsa-1", "RS256", &rsaKey.PublicKey))}
+
+	p, err := NewJWKSKeyProvider(context.Background(), source, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWKSKeyProvider: %v", err)
+	}
+	defer p.Close()
+	ctx := context.Background()
+	tokenWith := func(method jwt.SigningMethod, kid string) *jwt.Token {
+		token := jwt.New(method)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		return token
+	}
+
+	if _, err := p.VerificationKey(ctx, tokenWith(jwt.SigningMethodRS256, "rsa-1")); err != nil {
+		t.Errorf("known kid: %v", err)
+	}
+	if _, err := p.VerificationKey(ctx, tokenWith(jwt.SigningMethodRS256, "")); err != nil {
+		t.Errorf("token without kid against a single-key set: %v", err)
+	}
+	if _, err := p.VerificationKey(ctx, tokenWith(jwt.SigningMethodPS256, "rsa-1")); err == nil {
+		t.Error("alg different from the JWK alg was accepted")
+	}
+	if _, err := p.VerificationKey(ctx, tokenWith(jwt.SigningMethodHS256, "rsa-1")); err == nil {
+		t.Error("HS256 token accepted with an RSA JWK")
+	}
+
+	// Kid baru setelah rotasi memicu satu refresh on-demand.
+	source.set(jwksDocument(t,
+		mustJWK(t, "rsa-1", "RS256", &rsaKey.PublicKey),
+		mustJWK(t, "ed-1", "EdDSA", edPub),
+	))
+	p.minRefreshInterval = 0
+	before := source.fetches.Load()
+	if _, err := p.VerificationKey(ctx, tokenWith(jwt.SigningMethodEdDSA, "ed-1")); err != nil {
+		t.Errorf("rotated kid: %v", err)
+	}
+	if got := source.fetches.Load() - before; got != 1 {
+		t.Errorf("rotated kid triggered %d fetches, want 1", got)
+	}
+
+	// Kid karangan tidak memicu fetch lagi dalam minRefreshInterval.
+	p.minRefreshInterval = time.Hour
+	before = source.fetches.Load()
+	for i := 0; i < 3; i++ {
+		if _, err := p.VerificationKey(ctx, tokenWith(jwt.SigningMethodRS256, "bogus")); !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("unknown kid error = %v, want %v", err, ErrUnknownKeyID)
+		}
+	}
+	if got := source.fetches.Load() - before; got != 0 {
+		t.Errorf("unknown kid within minRefreshInterval triggered %d fetches, want 0", got)
+	}
+}
+
+func TestJWKSKeyProviderFailsFastDuringRefresh(t *testing.T) {
+	rsaKey := rsaTestKey(t)
+	source := &stubJWKSSource{doc: jwksDocument(t, mustJWK(t, "rsa-1", "RS256", &rsaKey.PublicKey))}
+	p, err := NewJWKSKeyProvider(context.Background(), source, time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWKSKeyProvider: %v", err)
+	}
+	defer p.Close()
+	p.minRefreshInterval = 0
+
+	// Refresh (misalnya dari loop background) tertahan di source.
+	block, started := make(chan struct{}), make(chan struct{})
+	source.mu.Lock()
+	source.block, source.started = block, started
+	source.mu.Unlock()
+	refreshDone := make(chan error)
+	go func() { refreshDone <- p.Refresh(context.Background()) }()
+	<-started
+
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Header["kid"] = "rotated"
+	start := time.Now()
+	_, err = p.VerificationKey(context.Background(), token)
+	if !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("error = %v, want %v", err, ErrUnknownKeyID)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("VerificationKey waited %v for the in-flight refresh", elapsed)
+	}
+
+	// Kunci yang sudah dikenal tetap bisa dipakai selama refresh berjalan.
+	token.Header["kid"] = "rsa-1"
+	if _, err := p.VerificationKey(context.Background(), token); err != nil {
+		t.Errorf("known kid during refresh: %v", err)
+	}
+
+	close(block)
+	if err := <-refreshDone; err != nil {
+		t.Errorf("Refresh: %v", err)
+	}
+}
@@ -3,10 +3,10 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -20,7 +20,16 @@ const (
 )
 
 // GetTenantIDFromContext adalah helper yang digunakan oleh abstraksi DB untuk mendapatkan tenantID.
+// Identity di context.Context (diisi JWTMiddleware, interceptor gRPC, atau WithIdentity) diutamakan;
+// key gin lama tetap didukung untuk handler yang mengoper *gin.Context secara langsung.
 func GetTenantIDFromContext(ctx context.Context) (string, error) {
+	if identity, ok := IdentityFrom(ctx); ok {
+		if identity.TenantID == "" {
+			return "", fmt.Errorf("tenant ID di context kosong")
+		}
+		return identity.TenantID, nil
+	}
+
 	// Gin context menggunakan Value, jadi kita ambil dari sana.
 	ginCtx, ok := ctx.(*gin.Context)
 	if ok {
@@ -35,102 +44,174 @@ func GetTenantIDFromContext(ctx context.Context) (string, error) {
 		return tenantID, nil
 	}
 
-	// Fallback untuk non-gin context (misalnya, gRPC)
-	val := ctx.Value(TenantIDKey)
-	if val == nil {
-		return "", fmt.Errorf("tenant ID tidak ditemukan di dalam context")
-	}
-	tenantID, ok := val.(string)
-	if !ok || tenantID == "" {
-		return "", fmt.Errorf("tenant ID di context bukan string atau kosong")
-	}
-	return tenantID, nil
+	return "", fmt.Errorf("tenant ID tidak ditemukan di dalam context")
 }
 
 // JWTMiddleware memvalidasi token JWT dan mengekstrak informasi pengguna.
 // FIX: Menerima redis.Client sebagai parameter, menghilangkan kebutuhan akan init() global.
+// Ini adalah wrapper kompatibilitas untuk NewJWTMiddleware dengan konfigurasi default
+// (Bearer token, HMAC dari env JWT_SECRET_KEY, claim sub/tid/jti). Seperti perilaku lama,
+// exp dan iat tidak wajib ada; iss dan aud hanya diperiksa jika JWT_ISSUER atau JWT_AUDIENCE
+// diset. Service baru sebaiknya memakai NewJWTMiddleware.
 func JWTMiddleware(redisClient *redis.Client) gin.HandlerFunc {
+	return NewJWTMiddleware(WithRedisClient(redisClient), legacyValidation())
+}
+
+// JWTMiddlewareWithKeyProvider sama seperti JWTMiddleware, tetapi kunci verifikasi
+// diambil dari KeyProvider (misalnya JWKSKeyProvider), sehingga service verifikator
+// tidak perlu memegang kunci penandatangan. Validasi claim sama longgarnya dengan JWTMiddleware.
+func JWTMiddlewareWithKeyProvider(redisClient *redis.Client, keys KeyProvider) gin.HandlerFunc {
+	return NewJWTMiddleware(WithRedisClient(redisClient), WithKeyProvider(keys), legacyValidation())
+}
+
+// NewJWTMiddleware membuat middleware validasi JWT yang dapat dikonfigurasi melalui Option.
+// Token wajib memiliki exp dan iat yang valid, serta iss dan aud yang cocok. Panic saat
+// registrasi jika issuer (WithIssuer atau env JWT_ISSUER) atau audience (WithAudience atau env
+// JWT_AUDIENCE) tidak dikonfigurasi, kecuali dimatikan secara eksplisit dengan
+// WithoutIssuerCheck/WithoutAudienceCheck.
+func NewJWTMiddleware(opts ...Option) gin.HandlerFunc {
+	o := newJWTOptions(opts)
+
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		claims, mapClaims, authErr := o.authenticate(c)
+		if authErr != nil {
+			o.errorResponder(c, authErr)
 			c.Abort()
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format, must be Bearer token"})
-			c.Abort()
-			return
-		}
+		c.Set(ClaimsKey, mapClaims)
+		setGinIdentity(c, NewIdentityFromClaims(claims))
 
-		secretKey := os.Getenv("JWT_SECRET_KEY")
-		if secretKey == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "JWT secret key not configured"})
-			c.Abort()
-			return
+		c.Next()
+	}
+}
+
+// extractToken mencoba setiap TokenLookup secara berurutan.
+func (o *jwtOptions) extractToken(c *gin.Context) (string, *AuthError) {
+	for _, lookup := range o.lookups {
+		token, authErr := lookup.Extract(c)
+		if authErr != nil {
+			return "", authErr
+		}
+		if token != "" {
+			return token, nil
 		}
+	}
+	return "", o.missingTokenError()
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(secretKey), nil
-		})
+// missingTokenError membangun pesan 401 ketika tidak ada token di lokasi mana pun.
+func (o *jwtOptions) missingTokenError() *AuthError {
+	message := "Authentication token required"
+	if len(o.lookups) == 1 {
+		message = o.lookups[0].Name + " required"
+	}
+	return &AuthError{Status: http.StatusUnauthorized, Message: message}
+}
 
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
-			c.Abort()
-			return
-		}
+func (o *jwtOptions) parserOptions() []jwt.ParserOption {
+	var parserOpts []jwt.ParserOption
+	if !o.legacy {
+		parserOpts = append(parserOpts, jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+	}
+	if !o.skipAudience {
+		parserOpts = append(parserOpts, jwt.WithAudience(o.audience))
+	}
+	if !o.skipIssuer {
+		parserOpts = append(parserOpts, jwt.WithIssuer(o.issuer))
+	}
+	if o.leeway > 0 {
+		parserOpts = append(parserOpts, jwt.WithLeeway(o.leeway))
+	}
+	return parserOpts
+}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			jti, exists := claims["jti"].(string)
-			if !exists {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token missing JTI claim"})
-				c.Abort()
-				return
-			}
-
-			// Gunakan klien Redis yang disuntikkan
-			_, err := redisClient.Get(context.Background(), jti).Result()
-			if err == nil { // Jika tidak ada error, berarti key ditemukan (token dicabut)
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
-				c.Abort()
-				return
-			}
-			if err != redis.Nil { // Jika errornya BUKAN "key not found", berarti ada masalah server
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token with store"})
-				c.Abort()
-				return
-			}
-			// Jika err == redis.Nil, lanjutkan (token valid)
-
-			if userID, exists := claims["sub"]; exists {
-				c.Set(UserIDKey, userID)
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID (sub) not found in token claims"})
-				c.Abort()
-				return
-			}
-			if tenantID, exists := claims["tid"]; exists {
-				c.Set(TenantIDKey, tenantID)
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Tenant ID (tid) not found in token claims"})
-				c.Abort()
-				return
-			}
-
-			c.Set(ClaimsKey, claims)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token or claims"})
-			c.Abort()
-			return
+// authenticate menjalankan seluruh validasi token untuk satu request.
+func (o *jwtOptions) authenticate(c *gin.Context) (*PrismClaims, jwt.MapClaims, *AuthError) {
+	tokenString, authErr := o.extractToken(c)
+	if authErr != nil {
+		return nil, nil, authErr
+	}
+	return o.verify(c.Request.Context(), tokenString)
+}
+
+// verify memvalidasi token mentah: tanda tangan, claim standar, layout claim Prism, dan pencabutan.
+// Dipakai bersama oleh middleware gin dan interceptor gRPC.
+func (o *jwtOptions) verify(ctx context.Context, tokenString string) (*PrismClaims, jwt.MapClaims, *AuthError) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return o.keys.VerificationKey(ctx, token)
+	}, o.parserOptions()...)
+
+	if errors.Is(err, ErrKeyNotConfigured) {
+		return nil, nil, &AuthError{Status: http.StatusInternalServerError, Message: "JWT secret key not configured"}
+	}
+	if err != nil {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid token", Err: err}
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid token or claims"}
+	}
+	claims, err := newPrismClaims(mapClaims, o.claimNames)
+	if err != nil {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid token or claims", Err: err}
+	}
+
+	if claims.TokenUse != "" && claims.TokenUse != TokenUseAccess {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Token is not an access token"}
+	}
+
+	names := o.claimNames
+	if claims.ID == "" {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Token missing JTI claim"}
+	}
+
+	if authErr := o.checkRevocation(ctx, claims); authErr != nil {
+		return nil, nil, authErr
+	}
+
+	if claims.Subject == "" {
+		return nil, nil, &AuthError{
+			Status:  http.StatusUnauthorized,
+			Message: fmt.Sprintf("User ID (%s) not found in token claims", names.UserID),
 		}
+	}
+	if claims.TenantID == "" {
+		return nil, nil, &AuthError{
+			Status:  http.StatusUnauthorized,
+			Message: fmt.Sprintf("Tenant ID (%s) not found in token claims", names.TenantID),
+		}
+	}
 
-		c.Next()
+	return claims, mapClaims, nil
+}
+
+// checkRevocation memeriksa store pencabutan dengan batas waktu dan menerapkan failure policy.
+func (o *jwtOptions) checkRevocation(ctx context.Context, claims *PrismClaims) *AuthError {
+	checker := AnyRevoked(o.revocation, o.sessions)
+	if checker == nil {
+		return nil
+	}
+	if o.revocationWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.revocationWait)
+		defer cancel()
+	}
+
+	revoked, err := checker.IsRevoked(ctx, claims)
+	if err != nil {
+		if o.revocationFail == FailOpen {
+			log.Printf("Peringatan: pemeriksaan pencabutan token gagal, request diloloskan (fail-open): %v", err)
+			return nil
+		}
+		return &AuthError{Status: http.StatusInternalServerError, Message: "Could not verify token with store"}
+	}
+	if revoked {
+		return &AuthError{Status: http.StatusUnauthorized, Message: "Token has been revoked"}
 	}
+	return nil
 }
 
 // GetUserID mengekstrak ID pengguna dari konteks Gin setelah validasi middleware.
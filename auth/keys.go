@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrKeyNotConfigured dikembalikan ketika key provider belum memiliki kunci sama sekali
+// (misalnya env var kosong). Middleware memetakannya ke 500, bukan 401.
+var ErrKeyNotConfigured = errors.New("verification key not configured")
+
+// ErrUnknownKeyID dikembalikan ketika header `kid` tidak cocok dengan kunci mana pun.
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+// KeyProvider menyediakan kunci verifikasi untuk sebuah token yang sedang di-parse.
+// Implementasi memilih kunci berdasarkan header `kid` dan `alg` dari token.
+type KeyProvider interface {
+	VerificationKey(ctx context.Context, token *jwt.Token) (interface{}, error)
+}
+
+// KeyProviderFunc adalah adapter agar fungsi biasa bisa dipakai sebagai KeyProvider.
+type KeyProviderFunc func(ctx context.Context, token *jwt.Token) (interface{}, error)
+
+func (f KeyProviderFunc) VerificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	return f(ctx, token)
+}
+
+// HMACKeyProvider memverifikasi token HS256/HS384/HS512 dengan satu shared secret.
+type HMACKeyProvider struct {
+	Secret []byte
+}
+
+func (p HMACKeyProvider) VerificationKey(_ context.Context, token *jwt.Token) (interface{}, error) {
+	if len(p.Secret) == 0 {
+		return nil, ErrKeyNotConfigured
+	}
+	if err := checkMethodForKey(token.Method, p.Secret); err != nil {
+		return nil, err
+	}
+	return p.Secret, nil
+}
+
+// EnvHMACKeyProvider membaca shared secret dari environment variable pada setiap verifikasi.
+// Ini mempertahankan perilaku lama JWTMiddleware yang membaca JWT_SECRET_KEY per request.
+type EnvHMACKeyProvider struct {
+	EnvVar string
+}
+
+func (p EnvHMACKeyProvider) provider() HMACKeyProvider {
+	envVar := p.EnvVar
+	if envVar == "" {
+		envVar = "JWT_SECRET_KEY"
+	}
+	return HMACKeyProvider{Secret: []byte(os.Getenv(envVar))}
+}
+
+func (p EnvHMACKeyProvider) VerificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	return p.provider().VerificationKey(ctx, token)
+}
+
+// keyID mengambil header `kid` dari token, string kosong jika tidak ada.
+func keyID(token *jwt.Token) string {
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+// checkMethodForKey memastikan algoritma di header token sesuai dengan tipe kunci.
+// Tanpa pemeriksaan ini, token HS256 yang ditandatangani dengan kunci publik RSA
+// (algorithm confusion) bisa lolos.
+func checkMethodForKey(method jwt.SigningMethod, key interface{}) error {
+	var ok bool
+	switch key.(type) {
+	case []byte:
+		_, ok = method.(*jwt.SigningMethodHMAC)
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			ok = true
+		}
+	case *ecdsa.PublicKey:
+		_, ok = method.(*jwt.SigningMethodECDSA)
+	case ed25519.PublicKey:
+		_, ok = method.(*jwt.SigningMethodEd25519)
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	if !ok {
+		return fmt.Errorf("unexpected signing method: %v", method.Alg())
+	}
+	return nil
+}
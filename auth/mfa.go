@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMFA memastikan token diterbitkan setelah otentikasi multi-faktor (lihat PrismClaims.HasMFA).
+// Dipakai untuk route step-up, misalnya mengubah kredensial atau menyetujui pembayaran.
+// Harus dipasang setelah JWTMiddleware. Sesuai RFC 9470, penolakan berupa 401 dengan
+// error "insufficient_user_authentication" agar klien tahu harus meminta login ulang dengan 2FA.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := ClaimsFromContext(c)
+		if err != nil {
+			DefaultErrorResponder(c, &AuthError{Status: http.StatusForbidden, Message: "Claims not found in context"})
+			c.Abort()
+			return
+		}
+		if !claims.HasMFA() {
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_user_authentication", error_description="Multi-factor authentication required"`)
+			DefaultErrorResponder(c, &AuthError{Status: http.StatusUnauthorized, Message: "Multi-factor authentication required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
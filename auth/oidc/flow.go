@@ -0,0 +1,290 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrStateMismatch dikembalikan HandleCallback jika parameter state tidak cocok (indikasi CSRF).
+	ErrStateMismatch = errors.New("oidc: state mismatch")
+	// ErrInvalidIDToken dikembalikan jika ID token gagal divalidasi.
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+)
+
+// defaultSigningAlgs dipakai jika provider tidak mengiklankan algoritma. HMAC sengaja tidak
+// pernah diterima: client secret bukan kunci verifikasi yang layak untuk federasi.
+var defaultSigningAlgs = []string{"RS256"}
+
+// AuthRequest adalah permintaan otorisasi yang sedang berjalan. State, Nonce, dan CodeVerifier
+// harus disimpan caller (misal cookie terenkripsi berumur pendek atau Redis) sampai callback.
+type AuthRequest struct {
+	URL          string `json:"-"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// Tokens adalah respons token endpoint.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+}
+
+// TokenError adalah respons error OAuth2 dari token endpoint (RFC 6749 §5.2).
+type TokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oidc token endpoint returned %d: %s (%s)", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oidc token endpoint returned %d: %s", e.StatusCode, e.Code)
+}
+
+// IDToken adalah ID token yang sudah tervalidasi.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Audience      []string
+	ExpiresAt     time.Time
+	IssuedAt      time.Time
+	Nonce         string
+	Email         string
+	EmailVerified bool
+	Name          string
+	AMR           []string
+	// Claims berisi semua claim mentah untuk pemetaan role/atribut.
+	Claims map[string]interface{}
+}
+
+// AuthCodeURL membuat URL otorisasi dengan state, nonce, dan PKCE S256 yang baru.
+// extra (opsional) ditambahkan ke query, misalnya login_hint atau prompt.
+func (p *Provider) AuthCodeURL(extra url.Values) (*AuthRequest, error) {
+	state, err := randomString(24)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for k, v := range extra {
+		query[k] = v
+	}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	authURL := p.meta.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query.Encode()
+	} else {
+		authURL += "?" + query.Encode()
+	}
+	return &AuthRequest{URL: authURL, State: state, Nonce: nonce, CodeVerifier: verifier}, nil
+}
+
+// HandleCallback memvalidasi state dari redirect, menukar code, dan memvalidasi ID token
+// terhadap nonce dari pending. Ini jalur yang disarankan untuk endpoint callback.
+func (p *Provider) HandleCallback(ctx context.Context, pending *AuthRequest, state, code string) (*IDToken, *Tokens, error) {
+	if pending == nil || pending.State == "" || subtle.ConstantTimeCompare([]byte(pending.State), []byte(state)) != 1 {
+		return nil, nil, ErrStateMismatch
+	}
+	tokens, err := p.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	idToken, err := p.VerifyIDToken(ctx, tokens.IDToken, pending.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return idToken, tokens, nil
+}
+
+// Exchange menukar authorization code dengan token. Jika ClientSecret diisi, client
+// diotentikasi dengan client_secret_basic; jika tidak, client_id dikirim di body (public client).
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Tokens, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call token endpoint: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, tokenErr) != nil || tokenErr.Code == "" {
+			tokenErr.Code = "unexpected_response"
+		}
+		return nil, tokenErr
+	}
+
+	var tokens Tokens
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+	return &tokens, nil
+}
+
+// VerifyIDToken memvalidasi tanda tangan (JWKS provider), iss, aud, azp, exp, iat, dan nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	algs := p.meta.IDTokenSigningAlgValues
+	if len(algs) == 0 {
+		algs = defaultSigningAlgs
+	}
+	validAlgs := make([]string, 0, len(algs))
+	for _, alg := range algs {
+		if alg != "none" && !strings.HasPrefix(alg, "HS") {
+			validAlgs = append(validAlgs, alg)
+		}
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		return p.keys.VerificationKey(ctx, token)
+	},
+		jwt.WithValidMethods(validAlgs),
+		jwt.WithIssuer(p.meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(p.cfg.Leeway),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidIDToken
+	}
+
+	idToken := &IDToken{Claims: claims}
+	idToken.Issuer, _ = claims.GetIssuer()
+	idToken.Subject, _ = claims.GetSubject()
+	idToken.Audience, _ = claims.GetAudience()
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		idToken.ExpiresAt = exp.Time
+	}
+	if iat, _ := claims.GetIssuedAt(); iat != nil {
+		idToken.IssuedAt = iat.Time
+	}
+	idToken.Nonce = stringClaim(claims, "nonce")
+	idToken.Email = stringClaim(claims, "email")
+	idToken.Name = stringClaim(claims, "name")
+	idToken.EmailVerified = boolClaim(claims, "email_verified")
+	idToken.AMR = stringsClaim(claims, "amr")
+
+	if idToken.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	// OIDC Core §3.1.3.7: azp wajib sama dengan client ID jika token punya beberapa audience.
+	if azp := stringClaim(claims, "azp"); (azp != "" || len(idToken.Audience) > 1) && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: authorized party mismatch", ErrInvalidIDToken)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return idToken, nil
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// boolClaim menerima boolean maupun string "true", karena sebagian IdP mengirim email_verified sebagai string.
+func boolClaim(claims map[string]interface{}, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// stringsClaim menerima array string maupun satu string.
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// ErrNoRoleMapping dikembalikan jika tidak ada RoleRule yang cocok dan DefaultRole kosong.
+	ErrNoRoleMapping = errors.New("oidc: no role mapping matched")
+	// ErrEmailNotAllowed dikembalikan jika email tidak terverifikasi atau domainnya tidak diizinkan.
+	ErrEmailNotAllowed = errors.New("oidc: email not allowed for tenant")
+	// ErrRegistryClosed dikembalikan Registry setelah Close.
+	ErrRegistryClosed = errors.New("oidc: registry closed")
+)
+
+// RoleRule memetakan satu nilai claim eksternal (misal nama grup) ke role Prism.
+type RoleRule struct {
+	Value string `json:"value"`
+	Role  string `json:"role"`
+}
+
+// ClaimMapping adalah aturan pemetaan claim IdP ke identitas Prism untuk satu tenant.
+type ClaimMapping struct {
+	// SubjectClaim adalah claim ID user eksternal yang stabil. Default "sub".
+	SubjectClaim string `json:"subject_claim,omitempty"`
+	// EmailClaim default "email".
+	EmailClaim string `json:"email_claim,omitempty"`
+	// RoleClaim adalah claim yang dicocokkan dengan RoleRules, misalnya "groups" atau "roles".
+	RoleClaim string `json:"role_claim,omitempty"`
+	// RoleRules dievaluasi berurutan; rule pertama yang cocok menang.
+	RoleRules []RoleRule `json:"role_rules,omitempty"`
+	// DefaultRole dipakai jika tidak ada rule yang cocok. Kosong berarti login ditolak.
+	DefaultRole string `json:"default_role,omitempty"`
+	// AllowedEmailDomains (opsional) membatasi domain email yang boleh masuk ke tenant.
+	AllowedEmailDomains []string `json:"allowed_email_domains,omitempty"`
+	// RequireVerifiedEmail menolak token dengan email_verified false.
+	RequireVerifiedEmail bool `json:"require_verified_email,omitempty"`
+	// TrustedAMR adalah nilai amr dari IdP yang dipercaya, misalnya ["mfa", "otp"] untuk IdP
+	// yang memang mewajibkan MFA. Kosong berarti amr dari IdP dibuang, sehingga RequireMFA
+	// tidak pernah terpenuhi oleh claim yang tidak diperiksa Prism.
+	TrustedAMR []string `json:"trusted_amr,omitempty"`
+}
+
+// TenantConfig adalah konfigurasi federasi satu tenant, biasanya disimpan sebagai JSON
+// di pengaturan tenant atau Consul KV.
+type TenantConfig struct {
+	TenantID string       `json:"tenant_id"`
+	Provider Config       `json:"provider"`
+	Mapping  ClaimMapping `json:"mapping"`
+}
+
+// ExternalIdentity adalah hasil pemetaan ID token ke tenant dan role Prism. Subject adalah
+// ID user di IdP; auth service bertugas menautkannya ke user Prism (atau membuatnya).
+type ExternalIdentity struct {
+	TenantID string
+	Issuer   string
+	Subject  string
+	Email    string
+	Name     string
+	Role     string
+	AMR      []string
+}
+
+// TokenSubject membangun auth.TokenSubject untuk user Prism hasil penautan, sehingga
+// token Prism bisa dicetak dengan auth.Issuer. AMR hanya berisi nilai dari ClaimMapping.TrustedAMR.
+func (e *ExternalIdentity) TokenSubject(userID string) auth.TokenSubject {
+	return auth.TokenSubject{
+		UserID:   userID,
+		TenantID: e.TenantID,
+		Role:     e.Role,
+		AMR:      e.AMR,
+	}
+}
+
+// Map menerapkan aturan pemetaan ke ID token yang sudah tervalidasi.
+func (m ClaimMapping) Map(tenantID string, token *IDToken) (*ExternalIdentity, error) {
+	subjectClaim := m.SubjectClaim
+	if subjectClaim == "" {
+		subjectClaim = "sub"
+	}
+	emailClaim := m.EmailClaim
+	if emailClaim == "" {
+		emailClaim = "email"
+	}
+
+	identity := &ExternalIdentity{
+		TenantID: tenantID,
+		Issuer:   token.Issuer,
+		Subject:  stringClaim(token.Claims, subjectClaim),
+		Email:    stringClaim(token.Claims, emailClaim),
+		Name:     token.Name,
+		AMR:      trustedAMR(token.AMR, m.TrustedAMR),
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: claim '%s' is empty", ErrInvalidIDToken, subjectClaim)
+	}
+
+	if m.RequireVerifiedEmail && !token.EmailVerified {
+		return nil, fmt.Errorf("%w: email is not verified", ErrEmailNotAllowed)
+	}
+	if len(m.AllowedEmailDomains) > 0 && !emailDomainAllowed(identity.Email, m.AllowedEmailDomains) {
+		return nil, fmt.Errorf("%w: domain of '%s' is not allowed", ErrEmailNotAllowed, identity.Email)
+	}
+
+	identity.Role = m.DefaultRole
+	if m.RoleClaim != "" {
+		values := stringsClaim(token.Claims, m.RoleClaim)
+	rules:
+		for _, rule := range m.RoleRules {
+			for _, v := range values {
+				if v == rule.Value {
+					identity.Role = rule.Role
+					break rules
+				}
+			}
+		}
+	}
+	if identity.Role == "" {
+		return nil, ErrNoRoleMapping
+	}
+	return identity, nil
+}
+
+// trustedAMR menyaring amr dari IdP ke nilai yang ada di trusted.
+func trustedAMR(amr, trusted []string) []string {
+	var kept []string
+	for _, v := range amr {
+		for _, t := range trusted {
+			if v == t {
+				kept = append(kept, v)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+func emailDomainAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range domains {
+		if strings.EqualFold(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigLoader memuat TenantConfig untuk tenant; mengembalikan error jika tenant tidak memakai federasi.
+type ConfigLoader func(ctx context.Context, tenantID string) (*TenantConfig, error)
+
+// Registry menyimpan Provider per tenant yang dibuat secara lazy dari ConfigLoader,
+// sehingga auth service bisa melayani banyak IdP tanpa kode khusus per tenant.
+type Registry struct {
+	loader ConfigLoader
+	// loads menyatukan discovery bersamaan untuk tenant yang sama.
+	loads singleflight.Group
+
+	mu      sync.Mutex
+	tenants map[string]*registryEntry
+	// generations naik per tenant setiap Invalidate; load yang dimulai sebelumnya tidak disimpan,
+	// sementara load tenant lain tidak terpengaruh.
+	generations map[string]uint64
+	closed      bool
+}
+
+type registryEntry struct {
+	provider *Provider
+	mapping  ClaimMapping
+}
+
+// NewRegistry membuat Registry. Panggil Close saat shutdown.
+func NewRegistry(loader ConfigLoader) *Registry {
+	return &Registry{
+		loader:      loader,
+		tenants:     make(map[string]*registryEntry),
+		generations: make(map[string]uint64),
+	}
+}
+
+// entry mengembalikan Provider tenant, memuatnya jika belum ada. Discovery dan fetch JWKS
+// berjalan di luar lock sehingga IdP yang lambat hanya menahan login tenant tersebut.
+func (r *Registry) entry(ctx context.Context, tenantID string) (*registryEntry, error) {
+	r.mu.Lock()
+	e, ok := r.tenants[tenantID]
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return nil, ErrRegistryClosed
+	}
+	if ok {
+		return e, nil
+	}
+
+	// Load berjalan tanpa pembatalan dari request pertama agar request lain yang menunggu
+	// tenant yang sama tidak ikut gagal.
+	ch := r.loads.DoChan(tenantID, func() (interface{}, error) {
+		return r.load(context.WithoutCancel(ctx), tenantID)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*registryEntry), nil
+	}
+}
+
+func (r *Registry) load(ctx context.Context, tenantID string) (*registryEntry, error) {
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, ErrRegistryClosed
+		}
+		if e, ok := r.tenants[tenantID]; ok {
+			r.mu.Unlock()
+			return e, nil
+		}
+		generation := r.generations[tenantID]
+		r.mu.Unlock()
+
+		cfg, err := r.loader(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OIDC config for tenant '%s': %w", tenantID, err)
+		}
+		provider, err := NewProvider(ctx, cfg.Provider)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			provider.Close()
+			return nil, ErrRegistryClosed
+		}
+		if r.generations[tenantID] == generation {
+			e := &registryEntry{provider: provider, mapping: cfg.Mapping}
+			r.tenants[tenantID] = e
+			r.mu.Unlock()
+			return e, nil
+		}
+		r.mu.Unlock()
+		// Konfigurasi tenant di-invalidate selama load; buang hasilnya dan muat ulang.
+		provider.Close()
+	}
+}
+
+// AuthCodeURL memulai login untuk tenant.
+func (r *Registry) AuthCodeURL(ctx context.Context, tenantID string, extra url.Values) (*AuthRequest, error) {
+	e, err := r.entry(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return e.provider.AuthCodeURL(extra)
+}
+
+// HandleCallback menyelesaikan login untuk tenant dan memetakan hasilnya ke ExternalIdentity.
+func (r *Registry) HandleCallback(ctx context.Context, tenantID string, pending *AuthRequest, state, code string) (*ExternalIdentity, *Tokens, error) {
+	e, err := r.entry(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	idToken, tokens, err := e.provider.HandleCallback(ctx, pending, state, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	identity, err := e.mapping.Map(tenantID, idToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return identity, tokens, nil
+}
+
+// Invalidate membuang Provider tenant agar konfigurasi dimuat ulang pada pemakaian berikutnya.
+func (r *Registry) Invalidate(tenantID string) {
+	r.mu.Lock()
+	e, ok := r.tenants[tenantID]
+	delete(r.tenants, tenantID)
+	r.generations[tenantID]++
+	r.mu.Unlock()
+	if ok {
+		e.provider.Close()
+	}
+}
+
+// Close menghentikan semua Provider. Setelah Close, Registry mengembalikan ErrRegistryClosed
+// dan load yang masih berjalan membuang Provider-nya.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for id, e := range r.tenants {
+		e.provider.Close()
+		delete(r.tenants, id)
+	}
+}
@@ -0,0 +1,318 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClaimMappingMap(t *testing.T) {
+	groups := ClaimMapping{
+		RoleClaim:   "groups",
+		RoleRules:   []RoleRule{{Value: "prism-admins", Role: "admin"}, {Value: "finance", Role: "accountant"}},
+		DefaultRole: "staff",
+	}
+
+	tests := []struct {
+		name    string
+		mapping ClaimMapping
+		token   IDToken
+		want    *ExternalIdentity
+		wantErr error
+	}{
+		{
+			name:    "first matching rule wins",
+			mapping: groups,
+			token:   IDToken{Issuer: "https://idp", Claims: map[string]interface{}{"sub": "ext-1", "email": "budi@acme.id", "groups": []interface{}{"finance", "prism-admins"}}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Issuer: "https://idp", Subject: "ext-1", Email: "budi@acme.id", Role: "admin"},
+		},
+		{
+			name:    "single string role claim",
+			mapping: groups,
+			token:   IDToken{Claims: map[string]interface{}{"sub": "ext-1", "groups": "finance"}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Subject: "ext-1", Role: "accountant"},
+		},
+		{
+			name:    "default role when no rule matches",
+			mapping: groups,
+			token:   IDToken{Claims: map[string]interface{}{"sub": "ext-1", "groups": []interface{}{"sales"}}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Subject: "ext-1", Role: "staff"},
+		},
+		{
+			name:    "no rule and no default role",
+			mapping: ClaimMapping{RoleClaim: "groups", RoleRules: groups.RoleRules},
+			token:   IDToken{Claims: map[string]interface{}{"sub": "ext-1", "groups": []interface{}{"sales"}}},
+			wantErr: ErrNoRoleMapping,
+		},
+		{
+			name:    "custom subject and email claims",
+			mapping: ClaimMapping{SubjectClaim: "oid", EmailClaim: "upn", DefaultRole: "staff"},
+			token:   IDToken{Claims: map[string]interface{}{"sub": "pairwise", "oid": "object-1", "upn": "budi@acme.id"}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Subject: "object-1", Email: "budi@acme.id", Role: "staff"},
+		},
+		{
+			name:    "empty subject claim",
+			mapping: ClaimMapping{SubjectClaim: "oid", DefaultRole: "staff"},
+			token:   IDToken{Claims: map[string]interface{}{"sub": "ext-1"}},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "unverified email rejected",
+			mapping: ClaimMapping{DefaultRole: "staff", RequireVerifiedEmail: true},
+			token:   IDToken{Claims: map[string]interface{}{"sub": "ext-1", "email": "budi@acme.id"}},
+			wantErr: ErrEmailNotAllowed,
+		},
+		{
+			name:    "allowed domain is case-insensitive",
+			mapping: ClaimMapping{DefaultRole: "staff", AllowedEmailDomains: []string{"acme.id"}},
+			token:   IDToken{EmailVerified: true, Claims: map[string]interface{}{"sub": "ext-1", "email": "budi@ACME.id"}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Subject: "ext-1", Email: "budi@ACME.id", Role: "staff"},
+		},
+		{
+			name:    "other domain rejected",
+			mapping: ClaimMapping{DefaultRole: "staff", AllowedEmailDomains: []string{"acme.id"}},
+			token:   IDToken{Claims: map[string]interface{}{"sub": "ext-1", "email": "budi@acme.id.evil.com"}},
+			wantErr: ErrEmailNotAllowed,
+		},
+		{
+			name:    "amr dropped without TrustedAMR",
+			mapping: ClaimMapping{DefaultRole: "staff"},
+			token:   IDToken{AMR: []string{"mfa", "pwd"}, Claims: map[string]interface{}{"sub": "ext-1"}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Subject: "ext-1", Role: "staff"},
+		},
+		{
+			name:    "amr filtered to TrustedAMR",
+			mapping: ClaimMapping{DefaultRole: "staff", TrustedAMR: []string{"mfa", "otp"}},
+			token:   IDToken{AMR: []string{"pwd", "mfa"}, Claims: map[string]interface{}{"sub": "ext-1"}},
+			want:    &ExternalIdentity{TenantID: "tenant-a", Subject: "ext-1", Role: "staff", AMR: []string{"mfa"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mapping.Map("tenant-a", &tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Map error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Map = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeIdP adalah IdP OIDC minimal: discovery, JWKS, dan token endpoint yang mengembalikan
+// ID token bertanda tangan ES256 dengan claims.
+type fakeIdP struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	claims jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := auth.NewJWK("idp-key", "ES256", &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(ProviderMetadata{
+			Issuer:                  idp.URL,
+			AuthorizationEndpoint:   idp.URL + "/authorize",
+			TokenEndpoint:           idp.URL + "/token",
+			JWKSURI:                 idp.URL + "/jwks",
+			IDTokenSigningAlgValues: []string{"ES256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{jwk}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, _ *http.Request) {
+		idp.mu.Lock()
+		claims := jwt.MapClaims{}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		idp.mu.Unlock()
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = "idp-key"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Tokens{AccessToken: "at", TokenType: "Bearer", IDToken: signed})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *fakeIdP) setClaims(claims jwt.MapClaims) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.claims = claims
+}
+
+// countingLoader menghitung pemanggilan ConfigLoader per tenant. Jika gate diisi, load tenant
+// tersebut menunggu sampai gate ditutup.
+type countingLoader struct {
+	idp     *fakeIdP
+	mapping ClaimMapping
+
+	mu      sync.Mutex
+	loads   map[string]int
+	gate    map[string]chan struct{}
+	started chan string
+}
+
+func newCountingLoader(idp *fakeIdP, mapping ClaimMapping) *countingLoader {
+	return &countingLoader{idp: idp, mapping: mapping, loads: make(map[string]int), gate: make(map[string]chan struct{}), started: make(chan string, 16)}
+}
+
+func (l *countingLoader) load(_ context.Context, tenantID string) (*TenantConfig, error) {
+	l.mu.Lock()
+	l.loads[tenantID]++
+	gate := l.gate[tenantID]
+	l.mu.Unlock()
+	l.started <- tenantID
+	if gate != nil {
+		<-gate
+	}
+	return &TenantConfig{
+		TenantID: tenantID,
+		Provider: Config{IssuerURL: l.idp.URL, ClientID: "prism", RedirectURL: "https://prism/callback"},
+		Mapping:  l.mapping,
+	}, nil
+}
+
+func (l *countingLoader) count(tenantID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.loads[tenantID]
+}
+
+func TestRegistry(t *testing.T) {
+	idp := newFakeIdP(t)
+	loader := newCountingLoader(idp, ClaimMapping{
+		RoleClaim:   "groups",
+		RoleRules:   []RoleRule{{Value: "prism-admins", Role: "admin"}},
+		DefaultRole: "staff",
+		TrustedAMR:  []string{"mfa"},
+	})
+	r := NewRegistry(loader.load)
+	ctx := context.Background()
+
+	pending, err := r.AuthCodeURL(ctx, "tenant-a", nil)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if _, err := r.AuthCodeURL(ctx, "tenant-a", nil); err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if got := loader.count("tenant-a"); got != 1 {
+		t.Errorf("loads after two logins = %d, want 1", got)
+	}
+
+	now := time.Now()
+	idp.setClaims(jwt.MapClaims{
+		"iss": idp.URL, "aud": "prism", "sub": "ext-1", "nonce": pending.Nonce,
+		"exp": now.Add(time.Minute).Unix(), "iat": now.Unix(),
+		"email": "budi@acme.id", "groups": []string{"prism-admins"}, "amr": []string{"pwd", "mfa"},
+	})
+	identity, _, err := r.HandleCallback(ctx, "tenant-a", pending, pending.State, "code")
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	want := &ExternalIdentity{TenantID: "tenant-a", Issuer: idp.URL, Subject: "ext-1", Email: "budi@acme.id", Role: "admin", AMR: []string{"mfa"}}
+	if !reflect.DeepEqual(identity, want) {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+	if _, _, err := r.HandleCallback(ctx, "tenant-a", pending, "forged", "code"); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("HandleCallback with wrong state: error = %v, want %v", err, ErrStateMismatch)
+	}
+
+	r.Invalidate("tenant-a")
+	if _, err := r.AuthCodeURL(ctx, "tenant-a", nil); err != nil {
+		t.Fatalf("AuthCodeURL after Invalidate: %v", err)
+	}
+	if got := loader.count("tenant-a"); got != 2 {
+		t.Errorf("loads after Invalidate = %d, want 2", got)
+	}
+
+	r.Close()
+	if _, err := r.AuthCodeURL(ctx, "tenant-a", nil); !errors.Is(err, ErrRegistryClosed) {
+		t.Errorf("AuthCodeURL after Close: error = %v, want %v", err, ErrRegistryClosed)
+	}
+	if _, err := r.AuthCodeURL(ctx, "tenant-b", nil); !errors.Is(err, ErrRegistryClosed) {
+		t.Errorf("AuthCodeURL for new tenant after Close: error = %v, want %v", err, ErrRegistryClosed)
+	}
+	if got := loader.count("tenant-b"); got != 0 {
+		t.Errorf("tenant-b loaded %d times after Close, want 0", got)
+	}
+}
+
+func TestRegistryInvalidateDuringLoad(t *testing.T) {
+	idp := newFakeIdP(t)
+
+	tests := []struct {
+		name      string
+		interrupt func(r *Registry)
+		wantLoads int
+		wantErr   error
+	}{
+		// Invalidate tenant lain tidak membuang load tenant-a yang sedang berjalan.
+		{"other tenant", func(r *Registry) { r.Invalidate("tenant-b") }, 1, nil},
+		{"same tenant", func(r *Registry) { r.Invalidate("tenant-a") }, 2, nil},
+		{"close", func(r *Registry) { r.Close() }, 1, ErrRegistryClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := newCountingLoader(idp, ClaimMapping{DefaultRole: "staff"})
+			gate := make(chan struct{})
+			loader.gate["tenant-a"] = gate
+			r := NewRegistry(loader.load)
+			defer r.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := r.AuthCodeURL(context.Background(), "tenant-a", nil)
+				done <- err
+			}()
+			<-loader.started
+			tt.interrupt(r)
+			// Load ulang (jika ada) tidak perlu ditahan lagi.
+			loader.mu.Lock()
+			delete(loader.gate, "tenant-a")
+			loader.mu.Unlock()
+			close(gate)
+
+			if err := <-done; !errors.Is(err, tt.wantErr) {
+				t.Errorf("AuthCodeURL error = %v, want %v", err, tt.wantErr)
+			}
+			if got := loader.count("tenant-a"); got != tt.wantLoads {
+				t.Errorf("loads = %d, want %d", got, tt.wantLoads)
+			}
+		})
+	}
+}
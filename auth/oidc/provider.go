@@ -0,0 +1,131 @@
+// Package oidc adalah relying party OAuth2/OpenID Connect untuk login tenant melalui IdP
+// mereka sendiri: discovery, alur authorization code + PKCE, validasi ID token terhadap JWKS
+// provider, dan pemetaan claim eksternal ke tenant/user/role Prism per tenant.
+//
+// Hasil federasi dipetakan ke auth.TokenSubject sehingga auth service tetap mencetak token
+// Prism sendiri dengan auth.Issuer; service lain tidak perlu mengenal IdP eksternal.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+)
+
+// ProviderMetadata adalah subset dokumen /.well-known/openid-configuration yang dipakai.
+type ProviderMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	EndSessionEndpoint            string   `json:"end_session_endpoint,omitempty"`
+	IDTokenSigningAlgValues       []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// Config adalah konfigurasi client untuk satu IdP.
+type Config struct {
+	IssuerURL    string `json:"issuer_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"` // Kosong untuk public client (hanya PKCE).
+	RedirectURL  string `json:"redirect_url"`
+	// Scopes default: openid, profile, email.
+	Scopes []string `json:"scopes,omitempty"`
+	// Leeway toleransi jam untuk exp/iat/nbf. Default 30 detik.
+	Leeway time.Duration `json:"-"`
+	// HTTPClient opsional; default client dengan timeout 10 detik.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// Discover mengambil metadata provider dari <issuerURL>/.well-known/openid-configuration
+// dan memastikan issuer di dokumen sama dengan issuerURL, sesuai OIDC Discovery 1.0 §4.3.
+func Discover(ctx context.Context, issuerURL string, httpClient *http.Client) (*ProviderMetadata, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	wellKnown := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build discovery request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document from '%s': %w", wellKnown, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d fetching OIDC discovery document from '%s'", resp.StatusCode, wellKnown)
+	}
+
+	var meta ProviderMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("invalid OIDC discovery document: %w", err)
+	}
+	// OpenID Connect Discovery 1.0 §4.3: issuer harus identik dengan URL yang dipakai discovery.
+	if meta.Issuer != issuerURL {
+		return nil, fmt.Errorf("OIDC issuer mismatch: expected '%s', discovery document says '%s'", issuerURL, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document for '%s' is missing required endpoints", issuerURL)
+	}
+	return &meta, nil
+}
+
+// Provider adalah relying party untuk satu IdP. Kunci verifikasi ID token dimuat dari
+// jwks_uri provider dan di-refresh di background; panggil Close saat tidak dipakai lagi.
+type Provider struct {
+	cfg        Config
+	meta       ProviderMetadata
+	keys       *auth.JWKSKeyProvider
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewProvider menjalankan discovery dan memuat JWKS provider.
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC config requires issuer URL, client ID and redirect URL")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = 30 * time.Second
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	meta, err := Discover(ctx, cfg.IssuerURL, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := auth.NewJWKSKeyProvider(ctx, auth.HTTPJWKSSource{URL: meta.JWKSURI, Client: httpClient}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS for '%s': %w", cfg.IssuerURL, err)
+	}
+
+	return &Provider{cfg: cfg, meta: *meta, keys: keys, httpClient: httpClient, now: time.Now}, nil
+}
+
+// Metadata mengembalikan metadata hasil discovery.
+func (p *Provider) Metadata() ProviderMetadata {
+	return p.meta
+}
+
+// Close menghentikan refresh JWKS di background.
+func (p *Provider) Close() {
+	p.keys.Close()
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Option mengonfigurasi middleware yang dibuat oleh NewJWTMiddleware.
+type Option func(*jwtOptions)
+
+type jwtOptions struct {
+	keys           KeyProvider
+	revocation     RevocationChecker
+	sessions       RevocationChecker
+	revocationFail RevocationFailurePolicy
+	revocationWait time.Duration
+	lookups        []TokenLookup
+	claimNames     ClaimNames
+	audience       string
+	issuer         string
+	skipAudience   bool
+	skipIssuer     bool
+	legacy         bool
+	leeway         time.Duration
+	errorResponder ErrorResponder
+	publicMethods  map[string]struct{}
+}
+
+func defaultJWTOptions() *jwtOptions {
+	return &jwtOptions{
+		keys:           EnvHMACKeyProvider{EnvVar: "JWT_SECRET_KEY"},
+		issuer:         os.Getenv("JWT_ISSUER"),
+		audience:       os.Getenv("JWT_AUDIENCE"),
+		revocationWait: 2 * time.Second,
+		lookups:        []TokenLookup{FromHeader("Authorization", "Bearer")},
+		claimNames:     DefaultClaimNames(),
+		errorResponder: DefaultErrorResponder,
+	}
+}
+
+// newJWTOptions menerapkan opts di atas default dan memastikan iss dan aud selalu diperiksa,
+// kecuali dimatikan secara eksplisit. Konfigurasi yang kurang menyebabkan panic saat registrasi
+// sehingga service tidak diam-diam menerima token untuk service atau issuer lain. Wrapper lama
+// (legacyValidation) hanya mencatat peringatan dan melewati pemeriksaan yang tidak dikonfigurasi.
+func newJWTOptions(opts []Option) *jwtOptions {
+	o := defaultJWTOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.issuer == "" && !o.skipIssuer {
+		if !o.legacy {
+			panic("auth: JWT issuer is not configured; use WithIssuer, set JWT_ISSUER, or opt out with WithoutIssuerCheck")
+		}
+		log.Printf("Peringatan: JWT_ISSUER tidak diset, claim iss tidak diperiksa; gunakan NewJWTMiddleware dengan WithIssuer")
+		o.skipIssuer = true
+	}
+	if o.audience == "" && !o.skipAudience {
+		if !o.legacy {
+			panic("auth: JWT audience is not configured; use WithAudience, set JWT_AUDIENCE, or opt out with WithoutAudienceCheck")
+		}
+		log.Printf("Peringatan: JWT_AUDIENCE tidak diset, claim aud tidak diperiksa; gunakan NewJWTMiddleware dengan WithAudience")
+		o.skipAudience = true
+	}
+	return o
+}
+
+// legacyValidation mempertahankan validasi JWTMiddleware lama: exp dan iat tidak wajib, dan iss
+// atau aud hanya diperiksa jika JWT_ISSUER atau JWT_AUDIENCE diset.
+func legacyValidation() Option {
+	return func(o *jwtOptions) { o.legacy = true }
+}
+
+// ClaimNames memetakan nama claim di token ke identitas yang dibutuhkan middleware.
+type ClaimNames struct {
+	UserID   string
+	TenantID string
+	TokenID  string
+}
+
+// DefaultClaimNames mengembalikan layout claim yang dipakai token Prism: sub, tid, jti.
+func DefaultClaimNames() ClaimNames {
+	return ClaimNames{UserID: "sub", TenantID: "tid", TokenID: "jti"}
+}
+
+// AuthError adalah kegagalan otentikasi/otorisasi beserta status HTTP yang sesuai.
+type AuthError struct {
+	Status  int
+	Message string
+	Err     error // Detail opsional; DefaultErrorResponder mengirimnya sebagai "details".
+}
+
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus memetakan AuthError ke status gRPC sehingga status.FromError mengenalinya
+// ketika AuthError dikembalikan langsung dari interceptor.
+func (e *AuthError) GRPCStatus() *status.Status {
+	var code codes.Code
+	switch e.Status {
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	default:
+		code = codes.Internal
+	}
+	return status.New(code, e.Message)
+}
+
+// ErrorResponder menulis respons untuk AuthError. Middleware memanggil c.Abort() setelahnya.
+type ErrorResponder func(c *gin.Context, err *AuthError)
+
+// DefaultErrorResponder menulis body JSON {"error": ..., "details": ...} seperti perilaku awal JWTMiddleware.
+func DefaultErrorResponder(c *gin.Context, err *AuthError) {
+	body := gin.H{"error": err.Message}
+	if err.Err != nil {
+		body["details"] = err.Err.Error()
+	}
+	c.JSON(err.Status, body)
+}
+
+// TokenLookup mengekstrak token mentah dari request. Extract mengembalikan string kosong
+// dan error nil jika sumber ini tidak membawa token, sehingga lookup berikutnya dicoba.
+type TokenLookup struct {
+	Name    string
+	Extract func(c *gin.Context) (string, *AuthError)
+}
+
+// FromHeader membaca token dari header dengan skema tertentu, misal "Authorization: Bearer <token>".
+// Skema kosong berarti seluruh nilai header adalah token.
+func FromHeader(header, scheme string) TokenLookup {
+	return TokenLookup{
+		Name: header + " header",
+		Extract: func(c *gin.Context) (string, *AuthError) {
+			value := c.GetHeader(header)
+			if value == "" || scheme == "" {
+				return value, nil
+			}
+			prefix := scheme + " "
+			if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+				return "", &AuthError{
+					Status:  http.StatusUnauthorized,
+					Message: fmt.Sprintf("Invalid %s header format, must be %s token", strings.ToLower(header), scheme),
+				}
+			}
+			return value[len(prefix):], nil
+		},
+	}
+}
+
+// FromCookie membaca token dari cookie, berguna untuk aplikasi browser.
+func FromCookie(name string) TokenLookup {
+	return TokenLookup{
+		Name: name + " cookie",
+		Extract: func(c *gin.Context) (string, *AuthError) {
+			value, err := c.Cookie(name)
+			if err != nil {
+				return "", nil
+			}
+			return value, nil
+		},
+	}
+}
+
+// FromQuery membaca token dari query parameter, misalnya untuk koneksi WebSocket.
+func FromQuery(param string) TokenLookup {
+	return TokenLookup{
+		Name: param + " query parameter",
+		Extract: func(c *gin.Context) (string, *AuthError) {
+			return c.Query(param), nil
+		},
+	}
+}
+
+// WithKeyProvider mengatur sumber kunci verifikasi. Default: HMAC dari env JWT_SECRET_KEY.
+func WithKeyProvider(keys KeyProvider) Option {
+	return func(o *jwtOptions) { o.keys = keys }
+}
+
+// WithRedisClient mengaktifkan pemeriksaan pencabutan token di Redis memakai
+// RedisRevocationStore dengan layout key default (key = jti).
+// Tanpa opsi ini atau WithRevocationStore, pencabutan tidak diperiksa.
+func WithRedisClient(redisClient *redis.Client) Option {
+	return func(o *jwtOptions) {
+		if redisClient != nil {
+			o.revocation = NewRedisRevocationStore(redisClient, RedisRevocationOptions{})
+		}
+	}
+}
+
+// WithRevocationStore mengatur store pencabutan token, misalnya CachedRevocationStore.
+func WithRevocationStore(store RevocationChecker) Option {
+	return func(o *jwtOptions) { o.revocation = store }
+}
+
+// WithSessionStore menambahkan pemeriksaan sesi (misalnya session.Store) di samping store
+// pencabutan token. Token tanpa claim sid, atau yang sesinya sudah diakhiri, ditolak.
+// Timeout dan failure policy yang sama berlaku untuk keduanya.
+func WithSessionStore(sessions RevocationChecker) Option {
+	return func(o *jwtOptions) { o.sessions = sessions }
+}
+
+// WithRevocationFailurePolicy menentukan apakah request ditolak (FailClosed, default)
+// atau diloloskan (FailOpen) ketika store pencabutan gagal.
+func WithRevocationFailurePolicy(policy RevocationFailurePolicy) Option {
+	return func(o *jwtOptions) { o.revocationFail = policy }
+}
+
+// WithRevocationTimeout membatasi lama pemeriksaan pencabutan per request. Default 2 detik.
+func WithRevocationTimeout(timeout time.Duration) Option {
+	return func(o *jwtOptions) { o.revocationWait = timeout }
+}
+
+// WithTokenLookup mengganti daftar sumber token. Sumber dicoba berurutan; yang pertama
+// membawa token dipakai.
+func WithTokenLookup(lookups ...TokenLookup) Option {
+	return func(o *jwtOptions) { o.lookups = lookups }
+}
+
+// WithClaimNames mengganti nama claim untuk user ID, tenant ID, dan token ID.
+// Field kosong tetap memakai nilai default.
+func WithClaimNames(names ClaimNames) Option {
+	return func(o *jwtOptions) {
+		if names.UserID != "" {
+			o.claimNames.UserID = names.UserID
+		}
+		if names.TenantID != "" {
+			o.claimNames.TenantID = names.TenantID
+		}
+		if names.TokenID != "" {
+			o.claimNames.TokenID = names.TokenID
+		}
+	}
+}
+
+// WithAudience mewajibkan claim `aud` berisi nilai ini.
+func WithAudience(audience string) Option {
+	return func(o *jwtOptions) { o.audience = audience }
+}
+
+// WithIssuer mewajibkan claim `iss` sama dengan nilai ini.
+func WithIssuer(issuer string) Option {
+	return func(o *jwtOptions) { o.issuer = issuer }
+}
+
+// WithoutIssuerCheck mematikan pemeriksaan claim `iss`. Hanya untuk token dari sistem lama
+// yang tidak mengisi iss; tanda tangan, exp, dan iat tetap diperiksa.
+func WithoutIssuerCheck() Option {
+	return func(o *jwtOptions) { o.skipIssuer = true }
+}
+
+// WithoutAudienceCheck mematikan pemeriksaan claim `aud`, misalnya untuk service yang menerima
+// token dari banyak audience. Token yang ditujukan untuk service lain akan ikut diterima.
+func WithoutAudienceCheck() Option {
+	return func(o *jwtOptions) { o.skipAudience = true }
+}
+
+// WithLeeway memberi toleransi clock skew saat memeriksa exp, nbf, dan iat.
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *jwtOptions) { o.leeway = leeway }
+}
+
+// WithErrorResponder mengganti cara middleware menulis respons error.
+func WithErrorResponder(responder ErrorResponder) Option {
+	return func(o *jwtOptions) { o.errorResponder = responder }
+}
+
+// WithPublicMethods menandai method gRPC (nama lengkap, misal "/grpc.health.v1.Health/Check")
+// yang tidak memerlukan token. Hanya berlaku untuk interceptor gRPC.
+func WithPublicMethods(methods ...string) Option {
+	return func(o *jwtOptions) {
+		if o.publicMethods == nil {
+			o.publicMethods = make(map[string]struct{})
+		}
+		for _, m := range methods {
+			o.publicMethods[m] = struct{}{}
+		}
+	}
+}
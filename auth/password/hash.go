@@ -0,0 +1,245 @@
+// Package password menyediakan hashing password bersama untuk model.User.PasswordHash:
+// argon2id dan bcrypt dalam format string terenkode (PHC untuk argon2id, MCF bawaan untuk bcrypt),
+// deteksi rehash saat parameter berubah, dan validasi kebijakan password.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidHash dikembalikan jika string hash tidak bisa di-parse.
+	ErrInvalidHash = errors.New("password: invalid encoded hash")
+	// ErrUnsupportedHash dikembalikan untuk algoritma yang tidak dikenali.
+	ErrUnsupportedHash = errors.New("password: unsupported hash algorithm")
+	// ErrPasswordTooLong dikembalikan Hash bcrypt untuk password di atas BcryptMaxBytes.
+	ErrPasswordTooLong = errors.New("password: too long for bcrypt")
+)
+
+// BcryptMaxBytes adalah panjang input maksimum bcrypt; byte setelahnya tidak ikut di-hash
+// sehingga golang.org/x/crypto/bcrypt menolaknya.
+const BcryptMaxBytes = 72
+
+// Algorithm adalah algoritma yang dipakai Hasher untuk hash baru.
+type Algorithm string
+
+const (
+	AlgorithmArgon2id Algorithm = "argon2id"
+	AlgorithmBcrypt   Algorithm = "bcrypt"
+)
+
+// Argon2idParams adalah parameter argon2id. Memory dalam KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams mengikuti rekomendasi OWASP (m=64 MiB, t=3, p=2).
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+}
+
+// Config mengatur Hasher.
+type Config struct {
+	// Algorithm untuk hash baru. Default argon2id.
+	Algorithm Algorithm
+	Argon2id  Argon2idParams
+	// BcryptCost untuk hash bcrypt baru. Default bcrypt.DefaultCost.
+	BcryptCost int
+}
+
+// Hasher membuat dan memverifikasi hash password. Hash lama dengan algoritma atau parameter
+// berbeda tetap bisa diverifikasi; Verify melaporkan needsRehash agar caller bisa memperbaruinya saat login.
+type Hasher struct {
+	cfg Config
+}
+
+// NewHasher membuat Hasher dengan nilai default untuk field yang kosong.
+func NewHasher(cfg Config) *Hasher {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgorithmArgon2id
+	}
+	defaults := DefaultArgon2idParams()
+	if cfg.Argon2id.Memory == 0 {
+		cfg.Argon2id.Memory = defaults.Memory
+	}
+	if cfg.Argon2id.Iterations == 0 {
+		cfg.Argon2id.Iterations = defaults.Iterations
+	}
+	if cfg.Argon2id.Parallelism == 0 {
+		cfg.Argon2id.Parallelism = defaults.Parallelism
+	}
+	if cfg.Argon2id.SaltLength == 0 {
+		cfg.Argon2id.SaltLength = defaults.SaltLength
+	}
+	if cfg.Argon2id.KeyLength == 0 {
+		cfg.Argon2id.KeyLength = defaults.KeyLength
+	}
+	if cfg.BcryptCost == 0 {
+		cfg.BcryptCost = bcrypt.DefaultCost
+	}
+	return &Hasher{cfg: cfg}
+}
+
+var defaultHasher = NewHasher(Config{})
+
+// Hash membuat hash argon2id dengan parameter default.
+func Hash(password string) (string, error) {
+	return defaultHasher.Hash(password)
+}
+
+// Verify memverifikasi password terhadap hash dengan parameter default sebagai acuan rehash.
+func Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	return defaultHasher.Verify(password, encoded)
+}
+
+// NeedsRehash melaporkan apakah encoded berbeda dari parameter default.
+func NeedsRehash(encoded string) bool {
+	return defaultHasher.NeedsRehash(encoded)
+}
+
+// Hash membuat hash terenkode dari password.
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.cfg.Algorithm {
+	case AlgorithmArgon2id:
+		return h.hashArgon2id(password)
+	case AlgorithmBcrypt:
+		if len(password) > BcryptMaxBytes {
+			return "", ErrPasswordTooLong
+		}
+		out, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	default:
+		return "", ErrUnsupportedHash
+	}
+}
+
+// ConstrainPolicy menyesuaikan policy dengan batas algoritma Hasher: untuk bcrypt, MaxBytes
+// dibatasi ke BcryptMaxBytes agar setiap password yang lolos Validate juga bisa di-hash.
+func (h *Hasher) ConstrainPolicy(policy *Policy) {
+	if h.cfg.Algorithm == AlgorithmBcrypt && (policy.MaxBytes <= 0 || policy.MaxBytes > BcryptMaxBytes) {
+		policy.MaxBytes = BcryptMaxBytes
+	}
+}
+
+// Verify membandingkan password dengan hash secara constant-time. needsRehash bernilai true
+// jika password cocok tetapi hash dibuat dengan algoritma atau parameter yang berbeda dari Config.
+func (h *Hasher) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false, nil
+		}
+		return true, h.argon2idNeedsRehash(params), nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+		}
+		return true, h.cfg.Algorithm != AlgorithmBcrypt || cost != h.cfg.BcryptCost, nil
+
+	default:
+		return false, false, ErrUnsupportedHash
+	}
+}
+
+// NeedsRehash melaporkan apakah encoded dibuat dengan algoritma atau parameter (termasuk panjang
+// salt dan key) yang berbeda dari Config. Hash yang tidak bisa di-parse selalu perlu di-rehash.
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, _, _, err := decodeArgon2id(encoded)
+		return err != nil || h.argon2idNeedsRehash(params)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || h.cfg.Algorithm != AlgorithmBcrypt || cost != h.cfg.BcryptCost
+	default:
+		return true
+	}
+}
+
+func (h *Hasher) argon2idNeedsRehash(params Argon2idParams) bool {
+	want := h.cfg.Argon2id
+	return h.cfg.Algorithm != AlgorithmArgon2id ||
+		params.Memory != want.Memory || params.Iterations != want.Iterations ||
+		params.Parallelism != want.Parallelism ||
+		params.SaltLength != want.SaltLength || params.KeyLength != want.KeyLength
+}
+
+// hashArgon2id menghasilkan string PHC: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+func (h *Hasher) hashArgon2id(password string) (string, error) {
+	p := h.cfg.Argon2id
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+	parts := strings.Split(encoded, "$")
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: argon2 version %d", ErrUnsupportedHash, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	// argon2.IDKey panic untuk t atau p nol.
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
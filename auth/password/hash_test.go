@@ -0,0 +1,195 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Vektor dari test suite golang.org/x/crypto: argon2id (password "password", salt "somesalt",
+// t=1, m=64, p=1) dalam format PHC, dan bcrypt "allmine" cost 10.
+const (
+	argon2idVector = "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7"
+	bcryptVector   = "$2a$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga"
+)
+
+// testArgon2idParams kecil agar pengujian cepat.
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 18}
+
+func TestVerifyVectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		password  string
+		encoded   string
+		wantMatch bool
+	}{
+		{"argon2id", "password", argon2idVector, true},
+		{"argon2id wrong password", "Password", argon2idVector, false},
+		{"bcrypt", "allmine", bcryptVector, true},
+		{"bcrypt wrong password", "allmine!", bcryptVector, false},
+	}
+	h := NewHasher(Config{Argon2id: testArgon2idParams})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, _, err := h.Verify(tt.password, tt.encoded)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if match != tt.wantMatch {
+				t.Errorf("match = %v, want %v", match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		password string
+		wantErr  error
+	}{
+		{"argon2id", Config{Argon2id: testArgon2idParams}, "correct horse battery staple", nil},
+		{"argon2id accepts more than 72 bytes", Config{Argon2id: testArgon2idParams}, strings.Repeat("a", BcryptMaxBytes+1), nil},
+		{"bcrypt", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, "correct horse battery staple", nil},
+		{"bcrypt at 72 bytes", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, strings.Repeat("a", BcryptMaxBytes), nil},
+		{"bcrypt rejects 73 bytes", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, strings.Repeat("a", BcryptMaxBytes+1), ErrPasswordTooLong},
+		{"unknown algorithm", Config{Algorithm: "md5"}, "secret", ErrUnsupportedHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHasher(tt.cfg)
+			encoded, err := h.Hash(tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Hash error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			match, rehash, err := h.Verify(tt.password, encoded)
+			if err != nil || !match || rehash {
+				t.Errorf("Verify = (%v, %v, %v), want (true, false, nil)", match, rehash, err)
+			}
+			if match, _, _ := h.Verify("x"+tt.password, encoded); match {
+				t.Error("Verify matched a different password")
+			}
+		})
+	}
+
+	// Salt acak: password sama menghasilkan hash berbeda.
+	h := NewHasher(Config{Argon2id: testArgon2idParams})
+	a, _ := h.Hash("secret")
+	b, _ := h.Hash("secret")
+	if a == b {
+		t.Error("two argon2id hashes of the same password are identical")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	base := NewHasher(Config{Argon2id: testArgon2idParams})
+	argon, err := base.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := NewHasher(Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}).Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(mutate func(*Argon2idParams)) Config {
+		p := testArgon2idParams
+		mutate(&p)
+		return Config{Argon2id: p}
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		encoded string
+		want    bool
+	}{
+		{"same argon2id params", Config{Argon2id: testArgon2idParams}, argon, false},
+		{"memory changed", with(func(p *Argon2idParams) { p.Memory = 128 }), argon, true},
+		{"iterations changed", with(func(p *Argon2idParams) { p.Iterations = 2 }), argon, true},
+		{"parallelism changed", with(func(p *Argon2idParams) { p.Parallelism = 2 }), argon, true},
+		{"salt length changed", with(func(p *Argon2idParams) { p.SaltLength = 16 }), argon, true},
+		{"key length changed", with(func(p *Argon2idParams) { p.KeyLength = 32 }), argon, true},
+		{"argon2id to bcrypt", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, argon, true},
+		{"same bcrypt cost", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, bcryptHash, false},
+		{"bcrypt cost changed", Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"bcrypt to argon2id", Config{Argon2id: testArgon2idParams}, bcryptHash, true},
+		{"unparseable", Config{Argon2id: testArgon2idParams}, "$argon2id$garbage", true},
+		{"unknown algorithm", Config{Argon2id: testArgon2idParams}, "plaintext", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHasher(tt.cfg)
+			if got := h.NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+			// Untuk hash yang valid, Verify melaporkan hal yang sama.
+			if match, rehash, err := h.Verify("secret", tt.encoded); err == nil && (!match || rehash != tt.want) {
+				t.Errorf("Verify = (%v, %v), want (true, %v)", match, rehash, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    error
+	}{
+		{"wrong part count", "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ", ErrInvalidHash},
+		{"bad version field", "$argon2id$x$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7", ErrInvalidHash},
+		{"unsupported version", "$argon2id$v=16$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7", ErrUnsupportedHash},
+		{"zero iterations", "$argon2id$v=19$m=64,t=0,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7", ErrInvalidHash},
+		{"zero parallelism", "$argon2id$v=19$m=64,t=1,p=0$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7", ErrInvalidHash},
+		{"bad salt encoding", "$argon2id$v=19$m=64,t=1,p=1$!!!$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7", ErrInvalidHash},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$", ErrInvalidHash},
+		{"truncated bcrypt", "$2a$10$fooo", ErrInvalidHash},
+		{"argon2i", "$argon2i$v=19$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7", ErrUnsupportedHash},
+		{"plaintext", "password", ErrUnsupportedHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, _, err := Verify("password", tt.encoded)
+			if match || !errors.Is(err, tt.want) {
+				t.Errorf("Verify = (%v, %v), want (false, %v)", match, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstrainPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		maxBytes  int
+		want      int
+	}{
+		{"bcrypt unset", AlgorithmBcrypt, 0, BcryptMaxBytes},
+		{"bcrypt above limit", AlgorithmBcrypt, 100, BcryptMaxBytes},
+		{"bcrypt below limit kept", AlgorithmBcrypt, 64, 64},
+		{"argon2id unchanged", AlgorithmArgon2id, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultPolicy()
+			policy.MaxBytes = tt.maxBytes
+			NewHasher(Config{Algorithm: tt.algorithm}).ConstrainPolicy(policy)
+			if policy.MaxBytes != tt.want {
+				t.Errorf("MaxBytes = %d, want %d", policy.MaxBytes, tt.want)
+			}
+		})
+	}
+
+	// Setelah dibatasi, password di atas 72 byte ditolak Validate sebelum sampai ke Hash.
+	policy := DefaultPolicy()
+	NewHasher(Config{Algorithm: AlgorithmBcrypt}).ConstrainPolicy(policy)
+	if err := policy.Validate(strings.Repeat("é", 40)); err == nil {
+		t.Error("80-byte password passed a bcrypt-constrained policy")
+	}
+}
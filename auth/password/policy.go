@@ -0,0 +1,160 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kode pelanggaran kebijakan; stabil agar bisa diterjemahkan oleh frontend.
+const (
+	ViolationTooShort          = "too_short"
+	ViolationTooLong           = "too_long"
+	ViolationMissingUpper      = "missing_upper"
+	ViolationMissingLower      = "missing_lower"
+	ViolationMissingDigit      = "missing_digit"
+	ViolationMissingSymbol     = "missing_symbol"
+	ViolationBreached          = "breached"
+	ViolationContainsUserInput = "contains_user_input"
+)
+
+// PolicyError berisi semua pelanggaran kebijakan untuk satu password.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet policy: " + strings.Join(e.Violations, ", ")
+}
+
+// Policy adalah kebijakan password yang bisa dikonfigurasi per service atau per tenant.
+// MinLength dan MaxLength dihitung dalam karakter (rune), bukan byte.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes (opsional) membatasi panjang dalam byte UTF-8. Wajib untuk bcrypt, yang hanya
+	// menerima BcryptMaxBytes byte; lihat Hasher.ConstrainPolicy.
+	MaxBytes      int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// breached berisi SHA-1 (hex huruf besar) password yang diketahui bocor.
+	breached map[string]struct{}
+}
+
+// DefaultPolicy mengikuti NIST SP 800-63B: minimal 8 karakter, maksimal 128, tanpa aturan
+// komposisi wajib. Daftar password bocor dimuat terpisah dengan LoadBreachedList. Jika hash
+// memakai bcrypt, panggil Hasher.ConstrainPolicy agar batas 72 byte bcrypt ikut berlaku.
+func DefaultPolicy() *Policy {
+	return &Policy{MinLength: 8, MaxLength: 128}
+}
+
+// LoadBreachedList memuat daftar password bocor dari file lokal, satu entri per baris.
+// Baris boleh berupa password mentah atau hash SHA-1 hex dengan sufiks ":count" opsional
+// (format unduhan Have I Been Pwned). Baris kosong dan baris berawalan "#" diabaikan.
+func (p *Policy) LoadBreachedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("gagal membuka daftar password bocor: %w", err)
+	}
+	defer f.Close()
+
+	breached := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("gagal membaca daftar password bocor: %w", err)
+	}
+	p.breached = breached
+	return nil
+}
+
+// Validate memeriksa password terhadap kebijakan. userInputs (email, nama, dsb.) opsional;
+// password yang memuat salah satunya ditolak. Mengembalikan *PolicyError jika ada pelanggaran.
+func (p *Policy) Validate(password string, userInputs ...string) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, ViolationTooShort)
+	}
+	if (p.MaxLength > 0 && length > p.MaxLength) || (p.MaxBytes > 0 && len(password) > p.MaxBytes) {
+		violations = append(violations, ViolationTooLong)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, ViolationMissingUpper)
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, ViolationMissingLower)
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, ViolationMissingDigit)
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, ViolationMissingSymbol)
+	}
+
+	lowered := strings.ToLower(password)
+	for _, input := range userInputs {
+		// Input pendek (misal inisial) terlalu mudah muncul secara kebetulan.
+		if len(input) >= 4 && strings.Contains(lowered, strings.ToLower(input)) {
+			violations = append(violations, ViolationContainsUserInput)
+			break
+		}
+	}
+
+	if p.breached != nil {
+		if _, found := p.breached[sha1Hex(password)]; found {
+			violations = append(violations, ViolationBreached)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
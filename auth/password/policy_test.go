@@ -0,0 +1,106 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	strict := &Policy{MinLength: 8, MaxLength: 16, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name       string
+		policy     *Policy
+		password   string
+		userInputs []string
+		want       []string
+	}{
+		{"default accepts passphrase", DefaultPolicy(), "correct horse", nil, nil},
+		{"default has no composition rules", DefaultPolicy(), "aaaaaaaa", nil, nil},
+		{"too short", DefaultPolicy(), "short", nil, []string{ViolationTooShort}},
+		// Panjang dihitung dalam rune: 8 karakter multibyte lolos MinLength 8.
+		{"min length counts runes", DefaultPolicy(), "ééééééé", nil, []string{ViolationTooShort}},
+		{"multibyte at min length", DefaultPolicy(), "éééééééé", nil, nil},
+		{"too long", DefaultPolicy(), strings.Repeat("a", 129), nil, []string{ViolationTooLong}},
+		{"max bytes", &Policy{MaxLength: 128, MaxBytes: 10}, "ééééé é", nil, []string{ViolationTooLong}},
+		{"strict accepts composed password", strict, "Abcdef1!", nil, nil},
+		{"space counts as symbol", strict, "Abcdef1 ", nil, nil},
+		{"strict reports every missing class", strict, "abcdefgh", nil, []string{ViolationMissingUpper, ViolationMissingDigit, ViolationMissingSymbol}},
+		{"strict missing lower", strict, "ABCDEF1!", nil, []string{ViolationMissingLower}},
+		{"short and missing classes", strict, "A1!", nil, []string{ViolationTooShort, ViolationMissingLower}},
+		{"contains email local part", DefaultPolicy(), "Budi.Santoso2024", []string{"budi.santoso"}, []string{ViolationContainsUserInput}},
+		{"short user input ignored", DefaultPolicy(), "bsantoso-secret", []string{"bs"}, nil},
+		{"unrelated user input", DefaultPolicy(), "correct horse", []string{"budi@example.com", ""}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, tt.userInputs...)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			var perr *PolicyError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Validate = %v, want *PolicyError", err)
+			}
+			if !reflect.DeepEqual(perr.Violations, tt.want) {
+				t.Errorf("Violations = %v, want %v", perr.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyLoadBreachedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := strings.Join([]string{
+		"# komentar diabaikan",
+		"",
+		"letmein123",
+		// SHA-1 "password1" huruf kecil dengan jumlah kemunculan (format HIBP).
+		strings.ToLower(sha1Hex("password1")) + ":2413945",
+		sha1Hex("qwertyuiop"),
+		"  trustno1!  ",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultPolicy()
+	if err := policy.LoadBreachedList(path); err != nil {
+		t.Fatalf("LoadBreachedList: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		breached bool
+	}{
+		{"letmein123", true},
+		{"password1", true},
+		{"qwertyuiop", true},
+		{"trustno1!", true},
+		{"Letmein123", false},
+		{"correct horse", false},
+		{"# komentar diabaikan", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			var perr *PolicyError
+			got := errors.As(err, &perr) && slices.Contains(perr.Violations, ViolationBreached)
+			if got != tt.breached {
+				t.Errorf("breached = %v, want %v (err %v)", got, tt.breached, err)
+			}
+		})
+	}
+
+	if err := DefaultPolicy().LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBreachedList of a missing file returned nil")
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import "strings"
+
+// permissionSet adalah kumpulan izin efektif yang mendukung pola.
+//
+// Izin terdiri dari segmen yang dipisah ":" (misal "invoice:read:own"). Secara default hanya
+// pola wildcard eksplisit yang diperluas: segmen "*" cocok dengan satu segmen apa pun, dan "*"
+// di akhir pola mencakup satu atau lebih segmen sisanya. Jadi "invoice:*" mencakup
+// "invoice:read" dan "invoice:delete:all", "user:*:delete" mencakup "user:42:delete", dan "*"
+// saja mencakup semuanya. Izin tanpa "*" di akhir hanya cocok persis.
+//
+// Jika hierarchical aktif (lihat WithHierarchicalPermissions), setiap izin juga mencakup semua
+// izin yang lebih spesifik di bawahnya: "invoice:read" mencakup "invoice:read:own".
+type permissionSet struct {
+	exact        map[string]struct{}
+	patterns     [][]string
+	hierarchical bool
+}
+
+func newPermissionSet(perms ...map[string]struct{}) *permissionSet {
+	set := &permissionSet{exact: make(map[string]struct{})}
+	for _, m := range perms {
+		for p := range m {
+			set.add(p)
+		}
+	}
+	return set
+}
+
+func (s *permissionSet) add(perm string) {
+	if perm == "" {
+		return
+	}
+	if strings.Contains(perm, "*") {
+		s.patterns = append(s.patterns, strings.Split(perm, ":"))
+		return
+	}
+	s.exact[perm] = struct{}{}
+}
+
+// allows memeriksa apakah required tercakup oleh salah satu izin di set.
+func (s *permissionSet) allows(required string) bool {
+	if required == "" {
+		return false
+	}
+	if _, ok := s.exact[required]; ok {
+		return true
+	}
+	if s.hierarchical {
+		// Izin yang lebih umum (prefix segmen) juga mencakup required.
+		for i := len(required) - 1; i > 0; i-- {
+			if required[i] == ':' {
+				if _, ok := s.exact[required[:i]]; ok {
+					return true
+				}
+			}
+		}
+	}
+
+	if len(s.patterns) == 0 {
+		return false
+	}
+	segments := strings.Split(required, ":")
+	for _, pattern := range s.patterns {
+		if matchPermissionPattern(pattern, segments, s.hierarchical) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPermissionPattern memeriksa apakah pattern cocok dengan segments, dengan "*" cocok untuk
+// satu segmen apa pun. "*" di segmen terakhir pattern juga mencakup semua segmen sisanya;
+// selain itu jumlah segmen harus sama, kecuali prefix diizinkan (hierarchical).
+func matchPermissionPattern(pattern, segments []string, prefix bool) bool {
+	if len(pattern) > len(segments) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments) || prefix || pattern[len(pattern)-1] == "*"
+}
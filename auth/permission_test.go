@@ -0,0 +1,47 @@
+package auth
+
+import "testing"
+
+func TestPermissionSetAllows(t *testing.T) {
+	tests := []struct {
+		name         string
+		granted      []string
+		hierarchical bool
+		required     string
+		want         bool
+	}{
+		{"exact", []string{"invoice:read"}, false, "invoice:read", true},
+		{"exact mismatch", []string{"invoice:read"}, false, "invoice:create", false},
+		{"empty required", []string{"*"}, false, "", false},
+		{"exact does not cover child", []string{"invoice:read"}, false, "invoice:read:own", false},
+		{"exact does not cover parent", []string{"invoice:read:own"}, false, "invoice:read", false},
+		{"global wildcard", []string{"*"}, false, "invoice:delete:all", true},
+		{"trailing wildcard one segment", []string{"invoice:*"}, false, "invoice:read", true},
+		{"trailing wildcard many segments", []string{"invoice:*"}, false, "invoice:delete:all", true},
+		{"trailing wildcard needs a segment", []string{"invoice:*"}, false, "invoice", false},
+		{"trailing wildcard other resource", []string{"invoice:*"}, false, "user:read", false},
+		{"middle wildcard", []string{"user:*:delete"}, false, "user:42:delete", true},
+		{"middle wildcard wrong action", []string{"user:*:delete"}, false, "user:42:read", false},
+		{"middle wildcard does not cover child", []string{"user:*:delete"}, false, "user:42:delete:hard", false},
+		{"middle wildcard too short", []string{"user:*:delete"}, false, "user:42", false},
+		{"hierarchical exact covers child", []string{"invoice:read"}, true, "invoice:read:own", true},
+		{"hierarchical exact covers grandchild", []string{"invoice"}, true, "invoice:read:own", true},
+		{"hierarchical does not cover sibling", []string{"invoice:read"}, true, "invoice:readonly", false},
+		{"hierarchical middle wildcard covers child", []string{"user:*:delete"}, true, "user:42:delete:hard", true},
+		{"hierarchical does not cover parent", []string{"invoice:read:own"}, true, "invoice:read", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			granted := make(map[string]struct{}, len(tt.granted))
+			for _, p := range tt.granted {
+				granted[p] = struct{}{}
+			}
+			set := newPermissionSet(granted)
+			set.hierarchical = tt.hierarchical
+			if got := set.allows(tt.required); got != tt.want {
+				t.Errorf("allows(%q) with %q (hierarchical=%v) = %v, want %v",
+					tt.required, tt.granted, tt.hierarchical, got, tt.want)
+			}
+		})
+	}
+}
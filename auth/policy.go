@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rolePrefix menandai atom yang memeriksa role, bukan izin.
+const rolePrefix = "role:"
+
+// Policy adalah ekspresi otorisasi boolean yang sudah dikompilasi, misalnya
+// "invoice:approve && !role:intern" atau "(invoice:approve || role:finance_manager) && invoice:read".
+//
+// Atom adalah nama izin (dengan semantik wildcard yang sama seperti RequirePermission) atau
+// "role:<nama>" yang memeriksa Role/Roles di token. Operator: "!", "&&", "||", dan kurung;
+// "!" mengikat paling kuat, lalu "&&", lalu "||".
+type Policy struct {
+	expr string
+	root policyNode
+}
+
+// CompilePolicy mem-parse ekspresi policy. Kompilasi dilakukan sekali saat registrasi route.
+func CompilePolicy(expr string) (*Policy, error) {
+	p := &policyParser{tokens: tokenizePolicy(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("policy expression is empty")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", expr, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid policy %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return &Policy{expr: expr, root: root}, nil
+}
+
+// MustCompilePolicy seperti CompilePolicy tetapi panic jika ekspresi tidak valid,
+// sehingga kesalahan ketik policy terdeteksi saat service start, bukan saat request.
+func MustCompilePolicy(expr string) *Policy {
+	p, err := CompilePolicy(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Policy) String() string {
+	return p.expr
+}
+
+// RequirePolicy membuat middleware dari ekspresi policy. Panic jika ekspresi tidak valid.
+func (m *RBACMiddleware) RequirePolicy(expr string) gin.HandlerFunc {
+	policy := MustCompilePolicy(expr)
+	return m.requirePolicy(policy, fmt.Sprintf("Access denied by policy: '%s'", policy))
+}
+
+// RequireAny membuat middleware yang lolos jika pengguna memiliki minimal satu dari izin yang diberikan.
+// Semua argumen diperlakukan sebagai nama izin, termasuk yang diawali "role:". Panic jika
+// permissions kosong, agar daftar izin dari konfigurasi yang kosong tidak diam-diam menolak semua.
+func (m *RBACMiddleware) RequireAny(permissions ...string) gin.HandlerFunc {
+	mustHavePermissions("RequireAny", permissions)
+	policy := &Policy{expr: strings.Join(permissions, " || "), root: policyOr(permissionAtoms(permissions))}
+	return m.requirePolicy(policy, fmt.Sprintf("Access denied. Requires any of: %s", quoteList(permissions)))
+}
+
+// RequireAll membuat middleware yang lolos hanya jika pengguna memiliki semua izin yang diberikan.
+// Semua argumen diperlakukan sebagai nama izin, termasuk yang diawali "role:". Panic jika
+// permissions kosong, karena AND kosong bernilai true dan akan membuka route untuk semua pengguna.
+func (m *RBACMiddleware) RequireAll(permissions ...string) gin.HandlerFunc {
+	mustHavePermissions("RequireAll", permissions)
+	policy := &Policy{expr: strings.Join(permissions, " && "), root: policyAnd(permissionAtoms(permissions))}
+	return m.requirePolicy(policy, fmt.Sprintf("Access denied. Required permissions: %s", quoteList(permissions)))
+}
+
+func (m *RBACMiddleware) requirePolicy(policy *Policy, deniedMessage string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := ClaimsFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Claims not found in context"})
+			c.Abort()
+			return
+		}
+
+		allowed, authErr := m.Evaluate(c.Request.Context(), claims, policy)
+		if authErr == nil && !allowed {
+			authErr = &AuthError{Status: http.StatusForbidden, Message: deniedMessage}
+		}
+		if authErr != nil {
+			DefaultErrorResponder(c, authErr)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Evaluate mengevaluasi policy untuk claims. Izin efektif hanya diambil jika ekspresi
+// benar-benar membutuhkannya (misal policy yang hanya berisi role tidak memanggil user-service).
+// Untuk token impersonasi, policy yang mengevaluasi izin yang diblokir WithImpersonationRestrictions
+// selalu ditolak dengan 403, termasuk jika izin itu dinegasikan.
+func (m *RBACMiddleware) Evaluate(ctx context.Context, claims *PrismClaims, policy *Policy) (bool, *AuthError) {
+	env := &policyEnv{ctx: ctx, m: m, claims: claims}
+	allowed := policy.root.eval(env)
+	if env.err != nil {
+		return false, &AuthError{Status: http.StatusInternalServerError, Message: "Could not verify permissions", Err: env.err}
+	}
+	if env.blocked != "" {
+		return false, &AuthError{
+			Status:  http.StatusForbidden,
+			Message: fmt.Sprintf("Access denied. Permission '%s' is not available while impersonating", env.blocked),
+		}
+	}
+	return allowed, nil
+}
+
+// policyEnv memuat izin efektif secara lazy, paling banyak sekali per evaluasi.
+type policyEnv struct {
+	ctx    context.Context
+	m      *RBACMiddleware
+	claims *PrismClaims
+	perms  *permissionSet
+	scope  *permissionSet
+	err    error
+	// blocked adalah izin pertama yang diblokir selama impersonasi. Evaluate menolak seluruh
+	// policy jika terisi, karena "!izin" tidak boleh mengubah blokir menjadi izin.
+	blocked string
+}
+
+func (e *policyEnv) hasPermission(permission string) bool {
+	if e.m.blockedWhileImpersonating(e.claims, permission) {
+		if e.blocked == "" {
+			e.blocked = permission
+		}
+		return false
+	}
+	if e.perms == nil && e.err == nil {
+		roles := e.claims.AllRoles()
+		if len(roles) == 0 {
+			e.perms = newPermissionSet()
+		} else {
+			e.perms, e.err = e.m.effectivePermissions(e.ctx, e.claims.TenantID, roles)
+		}
+		e.scope = e.m.permissionScope(e.claims)
+	}
+	if e.err != nil {
+		return false
+	}
+	return e.perms.allows(permission) && (e.scope == nil || e.scope.allows(permission))
+}
+
+type policyNode interface {
+	eval(env *policyEnv) bool
+}
+
+type policyAtom string
+
+func (a policyAtom) eval(env *policyEnv) bool {
+	if role, ok := strings.CutPrefix(string(a), rolePrefix); ok {
+		return env.claims.HasRole(role)
+	}
+	return env.hasPermission(string(a))
+}
+
+type policyNot struct{ operand policyNode }
+
+func (n policyNot) eval(env *policyEnv) bool { return !n.operand.eval(env) }
+
+type policyAnd []policyNode
+
+func (n policyAnd) eval(env *policyEnv) bool {
+	for _, child := range n {
+		if !child.eval(env) {
+			return false
+		}
+	}
+	return true
+}
+
+type policyOr []policyNode
+
+func (n policyOr) eval(env *policyEnv) bool {
+	for _, child := range n {
+		if child.eval(env) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyPermission adalah atom yang selalu memeriksa izin. Hanya bahasa ekspresi policy
+// (policyAtom) yang menafsirkan awalan "role:".
+type policyPermission string
+
+func (p policyPermission) eval(env *policyEnv) bool {
+	return env.hasPermission(string(p))
+}
+
+func permissionAtoms(permissions []string) []policyNode {
+	nodes := make([]policyNode, 0, len(permissions))
+	for _, p := range permissions {
+		nodes = append(nodes, policyPermission(p))
+	}
+	return nodes
+}
+
+func mustHavePermissions(name string, permissions []string) {
+	if len(permissions) == 0 {
+		panic(fmt.Sprintf("%s requires at least one permission", name))
+	}
+	for _, p := range permissions {
+		if strings.TrimSpace(p) == "" {
+			panic(fmt.Sprintf("%s: permission must not be empty", name))
+		}
+	}
+}
+
+func quoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "'" + item + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// tokenizePolicy memecah ekspresi menjadi operator dan atom.
+func tokenizePolicy(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		switch ch := expr[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			i++
+		case ch == '(' || ch == ')' || ch == '!':
+			tokens = append(tokens, string(ch))
+			i++
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n()!&|", rune(expr[i])) {
+				i++
+			}
+			if i == start {
+				// Karakter "&" atau "|" tunggal; biarkan parser melaporkannya.
+				i++
+			}
+			tokens = append(tokens, expr[start:i])
+		}
+	}
+	return tokens
+}
+
+// policyParser adalah recursive-descent parser:
+//
+//	or   = and { "||" and }
+//	and  = unary { "&&" unary }
+//	unary = "!" unary | "(" or ")" | atom
+type policyParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *policyParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *policyParser) parseOr() (policyNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := policyOr{left}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return nodes, nil
+}
+
+func (p *policyParser) parseAnd() (policyNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := policyAnd{left}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return nodes, nil
+}
+
+func (p *policyParser) parseUnary() (policyNode, error) {
+	tok := p.peek()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "!":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return policyNot{operand: operand}, nil
+	case "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return inner, nil
+	case ")", "&&", "||", "&", "|":
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	p.pos++
+	if tok == rolePrefix {
+		return nil, fmt.Errorf("role name missing in %q", tok)
+	}
+	return policyAtom(tok), nil
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompilePolicyEvaluate(t *testing.T) {
+	source := NewMemoryPermissionSource(RolePermissions{
+		"staff":   {"invoice:read", "invoice:create"},
+		"auditor": {"report:read"},
+	})
+	m := NewRBACMiddlewareWithSource(source, time.Minute)
+	defer m.Close()
+
+	staff := &PrismClaims{TenantID: "tenant-a", Role: "staff"}
+	auditor := &PrismClaims{TenantID: "tenant-a", Role: "auditor"}
+
+	tests := []struct {
+		name   string
+		expr   string
+		claims *PrismClaims
+		want   bool
+	}{
+		{"single permission", "invoice:read", staff, true},
+		{"missing permission", "invoice:delete", staff, false},
+		{"role atom", "role:staff", staff, true},
+		{"role atom other role", "role:admin", staff, false},
+		{"and", "invoice:read && invoice:create", staff, true},
+		{"and short", "invoice:read && invoice:delete", staff, false},
+		{"or", "invoice:delete || invoice:read", staff, true},
+		{"not", "!invoice:delete", staff, true},
+		{"double not", "!!invoice:read", staff, true},
+		// && mengikat lebih kuat dari ||: a || (b && c).
+		{"and binds tighter than or", "report:read || invoice:read && invoice:delete", staff, false},
+		{"and binds tighter than or auditor", "report:read || invoice:read && invoice:delete", auditor, true},
+		{"parentheses override precedence", "(report:read || invoice:read) && invoice:create", staff, true},
+		{"parentheses override precedence auditor", "(report:read || invoice:read) && invoice:create", auditor, false},
+		{"not binds tighter than and", "!invoice:delete && invoice:read", staff, true},
+		{"not on group", "!(invoice:read || report:read)", staff, false},
+		{"whitespace", " ( role:auditor\t||\ninvoice:read ) ", staff, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := CompilePolicy(tt.expr)
+			if err != nil {
+				t.Fatalf("CompilePolicy(%q): %v", tt.expr, err)
+			}
+			got, authErr := m.Evaluate(context.Background(), tt.claims, policy)
+			if authErr != nil {
+				t.Fatalf("Evaluate(%q): %v", tt.expr, authErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateBlockedWhileImpersonating(t *testing.T) {
+	source := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read", "billing:refund"}})
+	m := NewRBACMiddlewareWithSource(source, time.Minute, WithImpersonationRestrictions("billing:refund"))
+	defer m.Close()
+
+	operator := &PrismClaims{TenantID: "tenant-a", Role: "staff", Actor: &ActorClaim{Subject: "op-1", TenantID: "tenant-a"}}
+	user := &PrismClaims{TenantID: "tenant-a", Role: "staff"}
+
+	tests := []struct {
+		name        string
+		expr        string
+		claims      *PrismClaims
+		want        bool
+		wantBlocked bool
+	}{
+		{"blocked permission", "billing:refund", operator, false, true},
+		// Negasi tidak boleh mengubah blokir menjadi izin.
+		{"negated blocked permission", "!billing:refund", operator, false, true},
+		{"negated blocked permission in and", "invoice:read && !billing:refund", operator, false, true},
+		{"blocked permission in or", "billing:refund || invoice:read", operator, false, true},
+		{"unrelated permission", "invoice:read", operator, true, false},
+		{"not impersonating", "billing:refund", user, true, false},
+		{"negated while not impersonating", "!billing:refund", user, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, authErr := m.Evaluate(context.Background(), tt.claims, MustCompilePolicy(tt.expr))
+			if tt.wantBlocked {
+				if authErr == nil || authErr.Status != http.StatusForbidden {
+					t.Fatalf("Evaluate(%q) = %v, %v; want 403", tt.expr, got, authErr)
+				}
+				return
+			}
+			if authErr != nil {
+				t.Fatalf("Evaluate(%q): %v", tt.expr, authErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePolicyErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"", "empty"},
+		{"   ", "empty"},
+		{"invoice:read &&", "unexpected end of expression"},
+		{"|| invoice:read", `unexpected "||"`},
+		{"invoice:read & invoice:create", `unexpected "&"`},
+		{"invoice:read | invoice:create", `unexpected "|"`},
+		{"(invoice:read", "missing closing parenthesis"},
+		{"invoice:read)", `unexpected ")"`},
+		{"()", `unexpected ")"`},
+		{"!", "unexpected end of expression"},
+		{"invoice:read invoice:create", `unexpected "invoice:create"`},
+		{"role:", "role name missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := CompilePolicy(tt.expr)
+			if err == nil {
+				t.Fatalf("CompilePolicy(%q) succeeded, want error", tt.expr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CompilePolicy(%q) error = %q, want it to contain %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequireAnyTreatsRolePrefixAsPermission(t *testing.T) {
+	source := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}})
+	m := NewRBACMiddlewareWithSource(source, time.Minute)
+	defer m.Close()
+
+	// RequireAny/RequireAll memeriksa izin, bukan role, meskipun namanya berawalan "role:".
+	policy := &Policy{root: policyOr(permissionAtoms([]string{"role:staff"}))}
+	got, authErr := m.Evaluate(context.Background(), &PrismClaims{TenantID: "tenant-a", Role: "staff"}, policy)
+	if authErr != nil {
+		t.Fatalf("Evaluate: %v", authErr)
+	}
+	if got {
+		t.Error(`permission "role:staff" granted from role membership`)
+	}
+}
+
+func TestMustHavePermissionsPanics(t *testing.T) {
+	tests := map[string][]string{
+		"nil":   nil,
+		"empty": {},
+		"blank": {"invoice:read", " "},
+	}
+	for name, perms := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("mustHavePermissions(%q) did not panic", perms)
+				}
+			}()
+			mustHavePermissions("RequireAny", perms)
+		})
+	}
+}
@@ -3,21 +3,39 @@ package auth
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	userv1 "github.com/Lumina-Enterprise-Solutions/prism-protobufs/gen/go/prism/user/v1"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-// permissionCache menyimpan izin untuk setiap peran dengan TTL.
+// permissionCache menyimpan izin untuk setiap peran dengan TTL, serta izin efektif
+// per kombinasi peran (setelah pewarisan) agar pola tidak perlu dibangun ulang setiap request.
+// Semua key menyertakan tenant ID, karena role dengan nama sama (misal "admin") dapat
+// didefinisikan berbeda di setiap tenant.
 type permissionCache struct {
 	mu          sync.RWMutex
-	permissions map[string]cachedPermissions
+	permissions map[roleCacheKey]cachedPermissions
+	effective   map[roleCacheKey]cachedEffective
+	// generation naik setiap invalidasi, agar hasil fetch yang dimulai sebelum invalidasi
+	// tidak menimpa cache dengan izin lama.
+	generation uint64
+}
+
+// roleCacheKey adalah key cache per tenant. Untuk cache izin efektif, role berisi
+// kombinasi role yang sudah diurutkan dan digabung dengan koma.
+type roleCacheKey struct {
+	tenantID string
+	role     string
 }
 
 type cachedPermissions struct {
@@ -25,107 +43,402 @@ type cachedPermissions struct {
 	expiresAt   time.Time
 }
 
-// RBACMiddleware adalah middleware yang stateful dengan koneksi gRPC dan cache.
+type cachedEffective struct {
+	permissions *permissionSet
+	expiresAt   time.Time
+}
+
+// RBACMiddleware adalah middleware yang stateful dengan PermissionSource (default user-service
+// lewat gRPC) dan cache.
 type RBACMiddleware struct {
-	userSvcClient userv1.UserServiceClient
-	cache         *permissionCache
-	cacheTTL      time.Duration
-	conn          *grpc.ClientConn
+	source   PermissionSource
+	cache    *permissionCache
+	cacheTTL time.Duration
+	conn     *grpc.ClientConn
+	// roleParents memetakan role ke role yang diwarisinya, misal "manager" -> ["staff"].
+	// Hierarki ini sama untuk semua tenant; lihat WithRoleHierarchy.
+	roleParents map[string][]string
+	// loads menyatukan fetch bersamaan untuk tenant dan role yang sama.
+	loads singleflight.Group
+	// staleWindow adalah berapa lama entri kedaluwarsa masih boleh dipakai sambil di-refresh di background.
+	staleWindow  time.Duration
+	invalidation RBACInvalidationSource
+	cancel       context.CancelFunc
+
+	// Ketahanan klien user-service; lihat rbac_client.go.
+	transportCreds credentials.TransportCredentials
+	perRPCCreds    credentials.PerRPCCredentials
+	callTimeout    time.Duration
+	retry          retryPolicy
+	breaker        *circuitBreaker
+	lastKnownGood  time.Duration
+
+	// impersonationBlocked berisi izin yang ditolak untuk token impersonasi; lihat impersonation.go.
+	impersonationBlocked *permissionSet
+	// hierarchicalPermissions membuat setiap izin mencakup izin yang lebih spesifik di bawahnya.
+	hierarchicalPermissions bool
+}
+
+// RBACOption mengonfigurasi RBACMiddleware.
+type RBACOption func(*RBACMiddleware)
+
+// WithRoleHierarchy mengatur pewarisan role: setiap role mendapat semua izin dari role
+// yang diwarisinya, secara transitif. Contoh: {"manager": {"staff"}, "admin": {"manager"}}.
+//
+// Hierarki ini global: struktur pewarisan yang sama berlaku di setiap tenant dan tidak dibaca
+// dari PermissionSource. Hanya izin setiap role yang bersifat per tenant, sehingga "manager"
+// di tenant-a mewarisi izin "staff" milik tenant-a. Tenant yang membutuhkan struktur pewarisan
+// berbeda harus mendefinisikan izin role-nya secara lengkap di PermissionSource, tanpa opsi ini.
+func WithRoleHierarchy(parents map[string][]string) RBACOption {
+	return func(m *RBACMiddleware) { m.roleParents = parents }
+}
+
+// WithHierarchicalPermissions membuat setiap izin juga mencakup semua izin yang lebih spesifik
+// di bawahnya, misalnya "invoice" mencakup "invoice:delete" dan "invoice:read" mencakup
+// "invoice:read:own". Tanpa opsi ini hanya pola yang berakhiran "*" (misal "invoice:*") yang
+// diperluas, sehingga grant persis yang sudah ada tidak ikut melebar.
+func WithHierarchicalPermissions() RBACOption {
+	return func(m *RBACMiddleware) { m.hierarchicalPermissions = true }
+}
+
+// WithStaleWhileRevalidate mengizinkan izin yang sudah kedaluwarsa dipakai selama window
+// setelah cacheTTL habis, sementara izin baru diambil di background. Request tidak perlu
+// menunggu user-service selama entri masih dalam window.
+func WithStaleWhileRevalidate(window time.Duration) RBACOption {
+	return func(m *RBACMiddleware) { m.staleWindow = window }
 }
 
-// NewRBACMiddleware membuat instance RBAC middleware baru.
-func NewRBACMiddleware(userServiceAddress string, cacheTTL time.Duration) (*RBACMiddleware, error) {
-	conn, err := grpc.NewClient(userServiceAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// WithInvalidationSource memasang sumber event invalidasi (misal RedisRBACInvalidations atau
+// ConsulRBACInvalidations). Listener berjalan di background sampai Close dipanggil, sehingga
+// perubahan role berlaku di seluruh cluster dalam hitungan detik, bukan setelah cacheTTL.
+func WithInvalidationSource(source RBACInvalidationSource) RBACOption {
+	return func(m *RBACMiddleware) { m.invalidation = source }
+}
+
+// NewRBACMiddleware membuat instance RBAC middleware baru. Secara default setiap panggilan ke
+// user-service dibatasi DefaultRBACCallTimeout, diulang hingga DefaultRBACMaxAttempts kali untuk
+// error sementara, dan dilindungi circuit breaker; lihat opsi di rbac_client.go untuk mengubahnya.
+func NewRBACMiddleware(userServiceAddress string, cacheTTL time.Duration, opts ...RBACOption) (*RBACMiddleware, error) {
+	m := newRBACMiddleware(cacheTTL, opts)
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(m.transportCreds)}
+	if m.perRPCCreds != nil {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(m.perRPCCreds))
+	}
+	conn, err := grpc.NewClient(userServiceAddress, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to user service for RBAC: %w", err)
 	}
+	m.conn = conn
+	m.source = &userServiceSource{
+		client:      userv1.NewUserServiceClient(conn),
+		callTimeout: m.callTimeout,
+		retry:       m.retry,
+		breaker:     m.breaker,
+	}
+	m.start()
+	return m, nil
+}
+
+// NewRBACMiddlewareWithSource membuat RBAC middleware yang mengambil izin dari source, tanpa
+// koneksi ke user-service. Dipakai untuk pengujian (MemoryPermissionSource) dan deployment
+// single-node (FilePermissionSource). Opsi khusus gRPC seperti WithTLSConfig diabaikan.
+// Jika source juga mengimplementasikan RBACInvalidationSource, perubahan di source langsung
+// membuang cache kecuali WithInvalidationSource diberikan.
+func NewRBACMiddlewareWithSource(source PermissionSource, cacheTTL time.Duration, opts ...RBACOption) *RBACMiddleware {
+	m := newRBACMiddleware(cacheTTL, opts)
+	m.source = source
+	if m.invalidation == nil {
+		if invalidation, ok := source.(RBACInvalidationSource); ok {
+			m.invalidation = invalidation
+		}
+	}
+	m.start()
+	return m
+}
 
-	return &RBACMiddleware{
-		userSvcClient: userv1.NewUserServiceClient(conn),
+func newRBACMiddleware(cacheTTL time.Duration, opts []RBACOption) *RBACMiddleware {
+	m := &RBACMiddleware{
 		cache: &permissionCache{
-			permissions: make(map[string]cachedPermissions),
+			permissions: make(map[roleCacheKey]cachedPermissions),
+			effective:   make(map[roleCacheKey]cachedEffective),
 		},
-		cacheTTL: cacheTTL,
-		conn:     conn, // PERUBAHAN: Simpan koneksi
-	}, nil
+		cacheTTL:       cacheTTL,
+		transportCreds: insecure.NewCredentials(),
+		callTimeout:    DefaultRBACCallTimeout,
+		retry: retryPolicy{
+			maxAttempts:    DefaultRBACMaxAttempts,
+			initialBackoff: DefaultRBACInitialBackoff,
+			maxBackoff:     DefaultRBACMaxBackoff,
+		},
+		breaker: &circuitBreaker{threshold: DefaultRBACBreakerThreshold, openFor: DefaultRBACBreakerOpenFor},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
+// start menjalankan listener invalidasi jika ada.
+func (m *RBACMiddleware) start() {
+	if m.invalidation != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancel = cancel
+		go m.listenInvalidations(ctx, m.invalidation)
+	}
+}
+
+// Close menghentikan listener invalidasi dan menutup koneksi ke user-service. PermissionSource
+// yang diberikan ke NewRBACMiddlewareWithSource tetap milik pemanggil dan tidak ditutup.
 func (m *RBACMiddleware) Close() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
 	if m.conn != nil {
 		return m.conn.Close()
 	}
 	return nil
 }
 
-// getPermissionsForRole mengambil izin untuk sebuah peran, menggunakan cache jika memungkinkan.
-func (m *RBACMiddleware) getPermissionsForRole(ctx context.Context, roleName string) (map[string]struct{}, error) {
+// getPermissionsForRole mengambil izin untuk sebuah peran di tenant, menggunakan cache jika memungkinkan.
+// expiresAt pada hasil bisa sudah lewat jika entri basi dipakai selama stale-while-revalidate.
+func (m *RBACMiddleware) getPermissionsForRole(ctx context.Context, tenantID, roleName string) (cachedPermissions, error) {
+	key := roleCacheKey{tenantID: tenantID, role: roleName}
 	m.cache.mu.RLock()
-	cached, found := m.cache.permissions[roleName]
+	cached, found := m.cache.permissions[key]
 	m.cache.mu.RUnlock()
 
-	if found && time.Now().Before(cached.expiresAt) {
-		return cached.permissions, nil
+	if found {
+		now := time.Now()
+		if now.Before(cached.expiresAt) {
+			return cached, nil
+		}
+		if now.Before(cached.expiresAt.Add(m.staleWindow)) {
+			m.refreshInBackground(ctx, key)
+			return cached, nil
+		}
+	}
+
+	// Jika tidak ada di cache atau sudah kedaluwarsa, ambil dari user-service. Fetch dijalankan
+	// tanpa pembatalan dari request pertama agar pemanggil lain yang menunggu hasil yang sama
+	// tidak ikut gagal jika request tersebut dibatalkan.
+	ch := m.loads.DoChan(key.flightKey(), func() (interface{}, error) {
+		return m.fetchPermissions(context.WithoutCancel(ctx), key)
+	})
+	select {
+	case <-ctx.Done():
+		return cachedPermissions{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return cachedPermissions{}, res.Err
+		}
+		return res.Val.(cachedPermissions), nil
 	}
+}
 
-	// Jika tidak ada di cache atau sudah kedaluwarsa, ambil dari user-service
-	req := &userv1.GetPermissionsForRoleRequest{RoleName: roleName}
-	resp, err := m.userSvcClient.GetPermissionsForRole(ctx, req)
+// refreshInBackground memuat ulang izin role tanpa menunggu hasilnya. Refresh yang sedang
+// berjalan untuk key yang sama tidak diduplikasi.
+func (m *RBACMiddleware) refreshInBackground(ctx context.Context, key roleCacheKey) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		res := <-m.loads.DoChan(key.flightKey(), func() (interface{}, error) {
+			return m.fetchPermissions(ctx, key)
+		})
+		if res.Err != nil {
+			log.Printf("Peringatan: gagal memperbarui izin role '%s' di tenant '%s', memakai cache lama: %v", key.role, key.tenantID, res.Err)
+		}
+	}()
+}
+
+// fetchPermissions mengambil izin role dari PermissionSource lalu menyimpannya ke cache.
+func (m *RBACMiddleware) fetchPermissions(ctx context.Context, key roleCacheKey) (cachedPermissions, error) {
+	m.cache.mu.RLock()
+	generation := m.cache.generation
+	m.cache.mu.RUnlock()
+
+	perms, err := m.source.PermissionsForRole(ctx, key.tenantID, key.role)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch permissions for role '%s': %w", roleName, err)
+		if entry, ok := m.lastKnownGoodEntry(key); ok {
+			log.Printf("Peringatan: sumber izin gagal, memakai izin terakhir role '%s' di tenant '%s': %v", key.role, key.tenantID, err)
+			return entry, nil
+		}
+		return cachedPermissions{}, fmt.Errorf("failed to fetch permissions for role '%s' in tenant '%s': %w", key.role, key.tenantID, err)
 	}
 
 	// Bangun set izin untuk lookup yang cepat
 	permsSet := make(map[string]struct{})
-	for _, p := range resp.GetPermissions() {
+	for _, p := range perms {
 		permsSet[p] = struct{}{}
 	}
 
-	// Simpan ke cache
+	// Simpan ke cache, kecuali ada invalidasi selama fetch berlangsung.
+	entry := cachedPermissions{permissions: permsSet, expiresAt: time.Now().Add(m.cacheTTL)}
 	m.cache.mu.Lock()
-	m.cache.permissions[roleName] = cachedPermissions{
-		permissions: permsSet,
-		expiresAt:   time.Now().Add(m.cacheTTL),
+	if m.cache.generation == generation {
+		m.cache.permissions[key] = entry
 	}
 	m.cache.mu.Unlock()
 
-	return permsSet, nil
+	return entry, nil
 }
 
-// RequirePermission membuat middleware yang memeriksa apakah pengguna memiliki izin yang diperlukan.
-func (m *RBACMiddleware) RequirePermission(requiredPermission string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		claims, exists := c.Get("claims")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Claims not found in context"})
-			c.Abort()
-			return
+// lastKnownGoodEntry mengembalikan entri cache terakhir untuk key jika WithLastKnownGood aktif
+// dan entri belum melewati batas umurnya.
+func (m *RBACMiddleware) lastKnownGoodEntry(key roleCacheKey) (cachedPermissions, bool) {
+	if m.lastKnownGood <= 0 {
+		return cachedPermissions{}, false
+	}
+	m.cache.mu.RLock()
+	entry, ok := m.cache.permissions[key]
+	m.cache.mu.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt.Add(m.lastKnownGood)) {
+		return cachedPermissions{}, false
+	}
+	return entry, true
+}
+
+// expandRoles menambahkan semua role yang diwarisi secara transitif menurut roleParents (sama
+// untuk semua tenant), lalu mengurutkannya sehingga kombinasi role yang sama selalu
+// menghasilkan key cache yang sama.
+func (m *RBACMiddleware) expandRoles(roles []string) []string {
+	seen := make(map[string]struct{}, len(roles))
+	queue := append([]string(nil), roles...)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if _, done := seen[role]; done {
+			continue // Juga melindungi dari siklus di hierarki.
 		}
+		seen[role] = struct{}{}
+		queue = append(queue, m.roleParents[role]...)
+	}
 
-		mapClaims, ok := claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid claims format"})
-			c.Abort()
-			return
+	expanded := make([]string, 0, len(seen))
+	for role := range seen {
+		expanded = append(expanded, role)
+	}
+	sort.Strings(expanded)
+	return expanded
+}
+
+// effectivePermissions menggabungkan izin semua role (termasuk yang diwarisi) menjadi satu
+// permissionSet, di-cache per tenant dan kombinasi role.
+func (m *RBACMiddleware) effectivePermissions(ctx context.Context, tenantID string, roles []string) (*permissionSet, error) {
+	expanded := m.expandRoles(roles)
+	key := roleCacheKey{tenantID: tenantID, role: strings.Join(expanded, ",")}
+
+	m.cache.mu.RLock()
+	cached, found := m.cache.effective[key]
+	generation := m.cache.generation
+	m.cache.mu.RUnlock()
+	if found && time.Now().Before(cached.expiresAt) {
+		return cached.permissions, nil
+	}
+
+	perRole := make([]map[string]struct{}, 0, len(expanded))
+	// Izin efektif kedaluwarsa bersama izin role yang paling awal kedaluwarsa, sehingga
+	// set yang dibangun dari entri basi segera dibangun ulang setelah refresh selesai.
+	expiresAt := time.Now().Add(m.cacheTTL)
+	for _, role := range expanded {
+		perms, err := m.getPermissionsForRole(ctx, tenantID, role)
+		if err != nil {
+			return nil, err
 		}
+		perRole = append(perRole, perms.permissions)
+		if perms.expiresAt.Before(expiresAt) {
+			expiresAt = perms.expiresAt
+		}
+	}
+	set := newPermissionSet(perRole...)
+	set.hierarchical = m.hierarchicalPermissions
 
-		role, ok := mapClaims["role"].(string)
-		if !ok || role == "" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found in token"})
-			c.Abort()
-			return
+	m.cache.mu.Lock()
+	if m.cache.generation == generation {
+		m.cache.effective[key] = cachedEffective{permissions: set, expiresAt: expiresAt}
+	}
+	m.cache.mu.Unlock()
+	return set, nil
+}
+
+// permissionScope mengembalikan claims.Permissions sebagai permissionSet, atau nil jika kosong.
+// Jika terisi, izin hanya diberikan bila dimiliki role dan juga tercakup scope ini, sehingga
+// API key atau token yang diberi sebagian izin role tidak mendapat seluruh izin role tersebut.
+func (m *RBACMiddleware) permissionScope(claims *PrismClaims) *permissionSet {
+	if len(claims.Permissions) == 0 {
+		return nil
+	}
+	scope := newPermissionSet()
+	scope.hierarchical = m.hierarchicalPermissions
+	for _, p := range claims.Permissions {
+		scope.add(p)
+	}
+	return scope
+}
+
+// CachedTenants mengembalikan tenant yang saat ini memiliki entri di cache izin, terurut.
+// Berguna untuk endpoint diagnostik dan untuk memutuskan tenant mana yang perlu di-invalidate.
+func (m *RBACMiddleware) CachedTenants() []string {
+	m.cache.mu.RLock()
+	seen := make(map[string]struct{})
+	for key := range m.cache.permissions {
+		seen[key.tenantID] = struct{}{}
+	}
+	for key := range m.cache.effective {
+		seen[key.tenantID] = struct{}{}
+	}
+	m.cache.mu.RUnlock()
+
+	tenants := make([]string, 0, len(seen))
+	for tenantID := range seen {
+		tenants = append(tenants, tenantID)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// checkPermission memeriksa apakah peran di claims memiliki izin yang diperlukan.
+// Dipakai bersama oleh middleware gin dan interceptor gRPC.
+func (m *RBACMiddleware) checkPermission(ctx context.Context, claims *PrismClaims, requiredPermission string) *AuthError {
+	roles := claims.AllRoles()
+	if len(roles) == 0 {
+		return &AuthError{Status: http.StatusForbidden, Message: "Role not found in token"}
+	}
+	if m.blockedWhileImpersonating(claims, requiredPermission) {
+		return &AuthError{
+			Status:  http.StatusForbidden,
+			Message: fmt.Sprintf("Access denied. Permission '%s' is not available while impersonating", requiredPermission),
 		}
+	}
+
+	// Ambil izin efektif untuk semua peran ini (dari cache atau gRPC)
+	userPermissions, err := m.effectivePermissions(ctx, claims.TenantID, roles)
+	if err != nil {
+		return &AuthError{Status: http.StatusInternalServerError, Message: "Could not verify permissions", Err: err}
+	}
 
-		// Ambil izin untuk peran ini (dari cache atau gRPC)
-		userPermissions, err := m.getPermissionsForRole(c.Request.Context(), role)
+	// Periksa apakah izin yang dibutuhkan tercakup, termasuk lewat pola wildcard, dan tidak
+	// berada di luar scope claims.Permissions.
+	scope := m.permissionScope(claims)
+	if !userPermissions.allows(requiredPermission) || (scope != nil && !scope.allows(requiredPermission)) {
+		return &AuthError{
+			Status:  http.StatusForbidden,
+			Message: fmt.Sprintf("Access denied. Required permission: '%s'", requiredPermission),
+		}
+	}
+	return nil
+}
+
+// RequirePermission membuat middleware yang memeriksa apakah pengguna memiliki izin yang diperlukan.
+func (m *RBACMiddleware) RequirePermission(requiredPermission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := ClaimsFromContext(c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify permissions", "details": err.Error()})
+			c.JSON(http.StatusForbidden, gin.H{"error": "Claims not found in context"})
 			c.Abort()
 			return
 		}
 
-		// Periksa apakah izin yang dibutuhkan ada
-		if _, hasPermission := userPermissions[requiredPermission]; !hasPermission {
-			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Access denied. Required permission: '%s'", requiredPermission)})
+		if authErr := m.checkPermission(c.Request.Context(), claims, requiredPermission); authErr != nil {
+			DefaultErrorResponder(c, authErr)
 			c.Abort()
 			return
 		}
@@ -138,20 +451,13 @@ func (m *RBACMiddleware) RequirePermission(requiredPermission string) gin.Handle
 // Untuk kontrol yang lebih halus, gunakan RequirePermission.
 func AdminOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		claims, exists := c.Get("claims")
-		if !exists {
+		claims, err := ClaimsFromContext(c)
+		if err != nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Claims not found in context"})
 			c.Abort()
 			return
 		}
-		mapClaims, ok := claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid claims format"})
-			c.Abort()
-			return
-		}
-		role, ok := mapClaims["role"].(string)
-		if !ok || role != "admin" {
+		if !claims.HasRole("admin") {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Administrator access required"})
 			c.Abort()
 			return
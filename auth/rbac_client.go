@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	userv1 "github.com/Lumina-Enterprise-Solutions/prism-protobufs/gen/go/prism/user/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Nilai default ketahanan klien user-service.
+const (
+	DefaultRBACCallTimeout      = 2 * time.Second
+	DefaultRBACMaxAttempts      = 3
+	DefaultRBACInitialBackoff   = 100 * time.Millisecond
+	DefaultRBACMaxBackoff       = time.Second
+	DefaultRBACBreakerThreshold = 5
+	DefaultRBACBreakerOpenFor   = 30 * time.Second
+)
+
+// ErrCircuitOpen dikembalikan tanpa memanggil user-service selama circuit breaker terbuka.
+var ErrCircuitOpen = errors.New("user service circuit breaker is open")
+
+// WithTransportCredentials mengganti kredensial transport gRPC ke user-service. Default insecure.
+func WithTransportCredentials(creds credentials.TransportCredentials) RBACOption {
+	return func(m *RBACMiddleware) { m.transportCreds = creds }
+}
+
+// WithTLSConfig memakai TLS (atau mTLS jika cfg berisi sertifikat klien) ke user-service.
+// Lihat LoadClientTLSConfig.
+func WithTLSConfig(cfg *tls.Config) RBACOption {
+	return WithTransportCredentials(credentials.NewTLS(cfg))
+}
+
+// WithPerRPCCredentials melampirkan kredensial ke setiap panggilan ke user-service, misalnya
+// token service dari servicetoken.TokenSource.PerRPCCredentials.
+func WithPerRPCCredentials(creds credentials.PerRPCCredentials) RBACOption {
+	return func(m *RBACMiddleware) { m.perRPCCreds = creds }
+}
+
+// LoadClientTLSConfig membuat tls.Config klien dari file PEM. caFile kosong berarti memakai
+// CA sistem; certFile dan keyFile diisi untuk mTLS.
+func LoadClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pemData, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file '%s': %w", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// WithCallTimeout mengatur deadline setiap percobaan panggilan ke user-service.
+func WithCallTimeout(timeout time.Duration) RBACOption {
+	return func(m *RBACMiddleware) { m.callTimeout = timeout }
+}
+
+// WithRetry mengatur jumlah percobaan dan backoff eksponensial (dengan jitter) untuk error
+// sementara seperti Unavailable. maxAttempts 1 mematikan retry.
+func WithRetry(maxAttempts int, initialBackoff, maxBackoff time.Duration) RBACOption {
+	return func(m *RBACMiddleware) {
+		m.retry = retryPolicy{maxAttempts: maxAttempts, initialBackoff: initialBackoff, maxBackoff: maxBackoff}
+	}
+}
+
+// WithCircuitBreaker membuka breaker setelah failureThreshold kegagalan berturut-turut; selama
+// openFor semua fetch langsung gagal dengan ErrCircuitOpen, lalu satu panggilan percobaan
+// diizinkan. failureThreshold <= 0 mematikan breaker.
+func WithCircuitBreaker(failureThreshold int, openFor time.Duration) RBACOption {
+	return func(m *RBACMiddleware) {
+		m.breaker = nil
+		if failureThreshold > 0 {
+			m.breaker = &circuitBreaker{threshold: failureThreshold, openFor: openFor}
+		}
+	}
+}
+
+// WithLastKnownGood mengizinkan izin terakhir yang berhasil dimuat dipakai jika user-service
+// gagal, selama entri tersebut belum lebih tua dari maxAge setelah kedaluwarsa. Entri yang
+// dibuang oleh invalidasi tidak pernah dipakai sebagai fallback. Default mati.
+func WithLastKnownGood(maxAge time.Duration) RBACOption {
+	return func(m *RBACMiddleware) { m.lastKnownGood = maxAge }
+}
+
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// backoff menghitung jeda sebelum percobaan ke-attempt (dimulai dari 1) dengan full jitter.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialBackoff << (attempt - 1)
+	if d <= 0 || (p.maxBackoff > 0 && d > p.maxBackoff) {
+		d = p.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+// TenantMetadataKey adalah metadata gRPC yang membawa tenant ID ke user-service saat
+// mengambil izin role, sehingga user-service dapat mengembalikan definisi role milik tenant.
+const TenantMetadataKey = "x-tenant-id"
+
+// userServiceSource adalah PermissionSource default yang mengambil izin dari user-service lewat
+// gRPC, dengan deadline per percobaan, retry, dan circuit breaker.
+type userServiceSource struct {
+	client      userv1.UserServiceClient
+	callTimeout time.Duration
+	retry       retryPolicy
+	breaker     *circuitBreaker
+}
+
+func (s *userServiceSource) PermissionsForRole(ctx context.Context, tenantID, role string) ([]string, error) {
+	req := &userv1.GetPermissionsForRoleRequest{RoleName: role}
+	if tenantID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, tenantID)
+	}
+
+	attempts := s.retry.maxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(s.retry.backoff(attempt)):
+			}
+		}
+		if err := s.breaker.allow(); err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
+			return nil, err
+		}
+
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.callTimeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, s.callTimeout)
+		}
+		resp, err := s.client.GetPermissionsForRole(callCtx, req)
+		cancel()
+
+		s.breaker.record(err == nil || !isUserServiceFailure(err))
+		if err == nil {
+			return resp.GetPermissions(), nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !isRetryable(err) {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// isUserServiceFailure membedakan kegagalan infrastruktur (yang menghitung untuk breaker)
+// dari jawaban normal user-service seperti NotFound atau PermissionDenied.
+func isUserServiceFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker adalah breaker sederhana closed -> open -> half-open. Nil berarti breaker mati.
+type circuitBreaker struct {
+	threshold int
+	openFor   time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// allow mengembalikan ErrCircuitOpen jika panggilan tidak boleh dilakukan. Dalam keadaan
+// half-open hanya satu panggilan percobaan yang diizinkan sampai hasilnya dicatat.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openFor {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		return nil
+	case breakerHalfOpen:
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// UserServiceHealthy melaporkan apakah circuit breaker ke user-service tertutup.
+// Berguna untuk readiness probe atau metrik. Selalu true jika memakai PermissionSource lain.
+func (m *RBACMiddleware) UserServiceHealthy() bool {
+	if m.breaker == nil {
+		return true
+	}
+	m.breaker.mu.Lock()
+	defer m.breaker.mu.Unlock()
+	return m.breaker.state == breakerClosed
+}
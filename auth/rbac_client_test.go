@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	userv1 "github.com/Lumina-Enterprise-Solutions/prism-protobufs/gen/go/prism/user/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{threshold: 2, openFor: 20 * time.Millisecond}
+
+	b.record(false)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after one failure = %v, want nil", err)
+	}
+	b.record(false)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after threshold = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after openFor = %v, want nil (half-open probe)", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second allow while half-open = %v, want %v", err, ErrCircuitOpen)
+	}
+	// Probe yang gagal langsung membuka breaker lagi, tanpa menunggu threshold.
+	b.record(false)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after failed probe = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow for second probe = %v, want nil", err)
+	}
+	b.record(true)
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("allow after successful probe = %v, want nil", err)
+		}
+	}
+
+	var disabled *circuitBreaker
+	disabled.record(false)
+	if err := disabled.allow(); err != nil {
+		t.Errorf("nil breaker allow = %v, want nil", err)
+	}
+}
+
+// fakeUserService mengembalikan errs berurutan, lalu permissions.
+type fakeUserService struct {
+	userv1.UserServiceClient
+
+	mu          sync.Mutex
+	calls       int
+	errs        []error
+	permissions []string
+	tenantIDs   []string
+	deadlines   []bool
+}
+
+func (f *fakeUserService) GetPermissionsForRole(ctx context.Context, _ *userv1.GetPermissionsForRoleRequest, _ ...grpc.CallOption) (*userv1.GetPermissionsForRoleResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	md, _ := metadata.FromOutgoingContext(ctx)
+	f.tenantIDs = append(f.tenantIDs, md.Get(TenantMetadataKey)...)
+	_, hasDeadline := ctx.Deadline()
+	f.deadlines = append(f.deadlines, hasDeadline)
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return &userv1.GetPermissionsForRoleResponse{Permissions: f.permissions}, nil
+}
+
+func TestUserServiceSource(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	notFound := status.Error(codes.NotFound, "role not found")
+	retry := retryPolicy{maxAttempts: 3, initialBackoff: time.Millisecond, maxBackoff: time.Millisecond}
+
+	tests := []struct {
+		name        string
+		errs        []error
+		threshold   int
+		wantCalls   int
+		wantErr     error
+		wantBreaker bool // breaker tertutup setelah panggilan
+	}{
+		{"success", nil, 5, 1, nil, true},
+		{"retries unavailable", []error{unavailable, unavailable}, 5, 3, nil, true},
+		{"gives up after max attempts", []error{unavailable, unavailable, unavailable}, 5, 3, unavailable, true},
+		{"not found is not retried", []error{notFound}, 5, 1, notFound, true},
+		// NotFound adalah jawaban normal user-service, bukan kegagalan infrastruktur.
+		{"not found does not trip breaker", []error{notFound}, 1, 1, notFound, true},
+		{"breaker stops retries", []error{unavailable, unavailable, unavailable}, 2, 2, ErrCircuitOpen, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeUserService{errs: tt.errs, permissions: []string{"invoice:read"}}
+			breaker := &circuitBreaker{threshold: tt.threshold, openFor: time.Minute}
+			source := &userServiceSource{client: client, callTimeout: time.Second, retry: retry, breaker: breaker}
+
+			perms, err := source.PermissionsForRole(context.Background(), "tenant-a", "staff")
+			if tt.wantErr == nil {
+				if err != nil || len(perms) != 1 || perms[0] != "invoice:read" {
+					t.Fatalf("PermissionsForRole = (%v, %v), want [invoice:read]", perms, err)
+				}
+			} else if !errors.Is(err, tt.wantErr) && status.Code(err) != status.Code(tt.wantErr) {
+				t.Fatalf("PermissionsForRole error = %v, want %v", err, tt.wantErr)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", client.calls, tt.wantCalls)
+			}
+			if closed := breaker.state == breakerClosed; closed != tt.wantBreaker {
+				t.Errorf("breaker closed = %v, want %v", closed, tt.wantBreaker)
+			}
+			for i, tenantID := range client.tenantIDs {
+				if tenantID != "tenant-a" {
+					t.Errorf("call %d tenant metadata = %q, want tenant-a", i, tenantID)
+				}
+			}
+			if len(client.tenantIDs) != client.calls {
+				t.Errorf("tenant metadata sent on %d of %d calls", len(client.tenantIDs), client.calls)
+			}
+			for i, hasDeadline := range client.deadlines {
+				if !hasDeadline {
+					t.Errorf("call %d has no deadline", i)
+				}
+			}
+		})
+	}
+
+	// Selama breaker terbuka, user-service tidak dipanggil sama sekali.
+	client := &fakeUserService{}
+	breaker := &circuitBreaker{threshold: 1, openFor: time.Minute}
+	breaker.record(false)
+	source := &userServiceSource{client: client, retry: retry, breaker: breaker}
+	if _, err := source.PermissionsForRole(context.Background(), "tenant-a", "staff"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("error with open breaker = %v, want %v", err, ErrCircuitOpen)
+	}
+	if client.calls != 0 {
+		t.Errorf("calls with open breaker = %d, want 0", client.calls)
+	}
+}
+
+func TestRBACLastKnownGood(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []RBACOption
+		invalidate bool
+		want       int
+	}{
+		{"disabled by default", nil, false, http.StatusInternalServerError},
+		{"serves last known good", []RBACOption{WithLastKnownGood(time.Hour)}, false, 0},
+		{"max age exceeded", []RBACOption{WithLastKnownGood(time.Millisecond)}, false, http.StatusInternalServerError},
+		// Entri yang dibuang invalidasi tidak boleh hidup lagi sebagai fallback.
+		{"not after invalidation", []RBACOption{WithLastKnownGood(time.Hour)}, true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}})
+			m := NewRBACMiddlewareWithSource(newCountingSource(memory), 5*time.Millisecond, tt.opts...)
+			defer m.Close()
+			claims := &PrismClaims{TenantID: "tenant-a", Role: "staff"}
+
+			if authErr := m.checkPermission(context.Background(), claims, "invoice:read"); authErr != nil {
+				t.Fatalf("initial check: %v", authErr)
+			}
+			time.Sleep(15 * time.Millisecond)
+			memory.SetError(status.Error(codes.Unavailable, "user-service down"))
+			if tt.invalidate {
+				m.InvalidateRole("tenant-a", "staff")
+			}
+
+			authErr := m.checkPermission(context.Background(), claims, "invoice:read")
+			got := 0
+			if authErr != nil {
+				got = authErr.Status
+			}
+			if got != tt.want {
+				t.Errorf("status = %d, want %d (%v)", got, tt.want, authErr)
+			}
+		})
+	}
+}
+
+func TestUserServiceHealthy(t *testing.T) {
+	m := NewRBACMiddlewareWithSource(NewMemoryPermissionSource(nil), time.Minute, WithCircuitBreaker(1, time.Minute))
+	defer m.Close()
+	if !m.UserServiceHealthy() {
+		t.Error("healthy = false before any failure")
+	}
+	m.breaker.record(false)
+	if m.UserServiceHealthy() {
+		t.Error("healthy = true with open breaker")
+	}
+
+	disabled := NewRBACMiddlewareWithSource(NewMemoryPermissionSource(nil), time.Minute, WithCircuitBreaker(0, 0))
+	defer disabled.Close()
+	if !disabled.UserServiceHealthy() {
+		t.Error("healthy = false with breaker disabled")
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPermissionFilePollInterval adalah interval pemeriksaan perubahan file izin.
+const DefaultPermissionFilePollInterval = 5 * time.Second
+
+// LoadStaticPermissions membaca StaticPermissions dari file JSON (ekstensi .json) atau YAML.
+func LoadStaticPermissions(path string) (*StaticPermissions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permission file '%s': %w", path, err)
+	}
+
+	var perms StaticPermissions
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &perms)
+	} else {
+		err = yaml.Unmarshal(data, &perms)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse permission file '%s': %w", path, err)
+	}
+	return &perms, nil
+}
+
+// FilePermissionSource membaca izin role dari file YAML/JSON (lihat StaticPermissions) dan
+// memuat ulang file saat berubah, untuk deployment single-node tanpa user-service. File
+// diperiksa secara berkala (mtime dan ukuran); file yang tidak valid diabaikan dan izin
+// terakhir tetap dipakai. Setiap reload dikirim sebagai RBACInvalidation.
+type FilePermissionSource struct {
+	path string
+
+	mu      sync.RWMutex
+	perms   *StaticPermissions
+	modTime time.Time
+	size    int64
+
+	hub    invalidationHub
+	cancel context.CancelFunc
+}
+
+// NewFilePermissionSource memuat file dan mulai memantaunya setiap pollInterval
+// (<= 0 berarti DefaultPermissionFilePollInterval). Panggil Close saat shutdown.
+func NewFilePermissionSource(path string, pollInterval time.Duration) (*FilePermissionSource, error) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPermissionFilePollInterval
+	}
+	s := &FilePermissionSource{path: path}
+	if _, err := s.reload(true); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.watch(ctx, pollInterval)
+	return s, nil
+}
+
+// Close menghentikan pemantauan file.
+func (s *FilePermissionSource) Close() {
+	s.cancel()
+}
+
+// Reload memuat ulang file sekarang juga, tanpa menunggu interval berikutnya, walaupun mtime
+// dan ukurannya tidak berubah.
+func (s *FilePermissionSource) Reload() error {
+	if _, err := s.reload(true); err != nil {
+		return err
+	}
+	s.hub.publish(RBACInvalidation{})
+	return nil
+}
+
+func (s *FilePermissionSource) PermissionsForRole(ctx context.Context, tenantID, role string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.perms.lookup(tenantID, role), nil
+}
+
+// SubscribeRBACInvalidations mengimplementasikan RBACInvalidationSource.
+func (s *FilePermissionSource) SubscribeRBACInvalidations(ctx context.Context, handler func(RBACInvalidation)) error {
+	return s.hub.subscribe(ctx, handler)
+}
+
+// reload membaca file jika mtime atau ukurannya berubah, atau selalu jika force. changed false
+// berarti file tidak dibaca ulang.
+func (s *FilePermissionSource) reload(force bool) (changed bool, err error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat permission file '%s': %w", s.path, err)
+	}
+	s.mu.RLock()
+	unchanged := !force && s.perms != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	perms, err := LoadStaticPermissions(s.path)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	s.perms = perms
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.mu.Unlock()
+	return true, nil
+}
+
+func (s *FilePermissionSource) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.reload(false)
+			if err != nil {
+				log.Printf("Peringatan: gagal memuat ulang file izin, memakai izin terakhir: %v", err)
+				continue
+			}
+			if changed {
+				log.Printf("File izin '%s' dimuat ulang.", s.path)
+				s.hub.publish(RBACInvalidation{})
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testPermissionYAML = `
+roles:
+  admin: ["*"]
+  staff: ["invoice:read"]
+tenants:
+  tenant-a:
+    staff: ["invoice:read", "invoice:create"]
+`
+
+func writePermissionFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadStaticPermissions(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr bool
+	}{
+		{"yaml", "perms.yaml", testPermissionYAML, false},
+		{"json", "perms.JSON", `{"roles":{"admin":["*"],"staff":["invoice:read"]},"tenants":{"tenant-a":{"staff":["invoice:read","invoice:create"]}}}`, false},
+		{"invalid yaml", "broken.yaml", "roles: [", true},
+		// Ekstensi .json selalu diparse sebagai JSON, walau isinya YAML yang valid.
+		{"yaml content in json file", "perms.json", testPermissionYAML, true},
+	}
+	want := &StaticPermissions{
+		Roles:   RolePermissions{"admin": {"*"}, "staff": {"invoice:read"}},
+		Tenants: map[string]RolePermissions{"tenant-a": {"staff": {"invoice:read", "invoice:create"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			writePermissionFile(t, path, tt.content)
+			got, err := LoadStaticPermissions(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadStaticPermissions error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadStaticPermissions = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := LoadStaticPermissions(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadStaticPermissions of a missing file returned nil")
+	}
+	if _, err := NewFilePermissionSource(filepath.Join(dir, "missing.yaml"), time.Minute); err == nil {
+		t.Error("NewFilePermissionSource of a missing file returned nil")
+	}
+}
+
+func TestFilePermissionSourceWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "perms.yaml")
+	writePermissionFile(t, path, testPermissionYAML)
+	source, err := NewFilePermissionSource(path, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewFilePermissionSource: %v", err)
+	}
+	defer source.Close()
+
+	lookup := func(tenantID, role string) []string {
+		perms, _ := source.PermissionsForRole(context.Background(), tenantID, role)
+		return perms
+	}
+	waitFor := func(tenantID, role string, want []string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !reflect.DeepEqual(lookup(tenantID, role), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s/%s = %v, want %v", tenantID, role, lookup(tenantID, role), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor("tenant-a", "staff", []string{"invoice:read", "invoice:create"})
+	waitFor("tenant-b", "staff", []string{"invoice:read"})
+
+	writePermissionFile(t, path, "roles:\n  staff: [\"report:read\"]\n")
+	waitFor("tenant-a", "staff", []string{"report:read"})
+	if got := lookup("tenant-a", "admin"); got != nil {
+		t.Errorf("removed role admin = %v, want nil", got)
+	}
+
+	// File rusak diabaikan; izin terakhir tetap dipakai.
+	writePermissionFile(t, path, "roles: [")
+	time.Sleep(30 * time.Millisecond)
+	if got := lookup("tenant-a", "staff"); !reflect.DeepEqual(got, []string{"report:read"}) {
+		t.Errorf("after invalid file = %v, want last valid permissions", got)
+	}
+	if err := source.Reload(); err == nil {
+		t.Error("Reload of an invalid file returned nil")
+	}
+}
+
+func TestFilePermissionSourceInvalidatesRBAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "perms.yaml")
+	writePermissionFile(t, path, testPermissionYAML)
+	// Interval panjang: perubahan hanya terlihat lewat Reload.
+	source, err := NewFilePermissionSource(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFilePermissionSource: %v", err)
+	}
+	defer source.Close()
+	m := NewRBACMiddlewareWithSource(source, time.Hour)
+	defer m.Close()
+
+	if !allowed(m, "tenant-a", "staff", "invoice:create") || allowed(m, "tenant-b", "staff", "invoice:create") {
+		t.Fatal("tenant override from file not applied")
+	}
+
+	// Isi berbeda dengan ukuran sama; Reload tetap membaca ulang file.
+	writePermissionFile(t, path, `
+roles:
+  admin: ["*"]
+  staff: ["invoice:read"]
+tenants:
+  tenant-a:
+    staff: ["invoice:read", "invoice:delete"]
+`)
+	// Listener invalidasi berlangganan secara asinkron, jadi Reload diulang sampai berlaku.
+	deadline := time.Now().Add(2 * time.Second)
+	for !allowed(m, "tenant-a", "staff", "invoice:delete") {
+		if time.Now().After(deadline) {
+			t.Fatal("RBAC cache not invalidated by Reload")
+		}
+		if err := source.Reload(); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if allowed(m, "tenant-a", "staff", "invoice:create") {
+		t.Error("old tenant permission still granted after reload")
+	}
+}
+
+func TestMemoryPermissionSourceInvalidatesRBAC(t *testing.T) {
+	source := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}})
+	m := NewRBACMiddlewareWithSource(source, time.Hour)
+	defer m.Close()
+
+	tests := []struct {
+		name   string
+		change func()
+		tenant string
+		want   string
+	}{
+		{"SetRole", func() { source.SetRole("staff", "invoice:create") }, "tenant-b", "invoice:create"},
+		{"SetTenantRole", func() { source.SetTenantRole("tenant-a", "staff", "invoice:delete") }, "tenant-a", "invoice:delete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed(m, tt.tenant, "staff", tt.want) // isi cache
+			deadline := time.Now().Add(2 * time.Second)
+			for !allowed(m, tt.tenant, "staff", tt.want) {
+				if time.Now().After(deadline) {
+					t.Fatalf("%s not applied to the RBAC cache", tt.name)
+				}
+				tt.change()
+				time.Sleep(5 * time.Millisecond)
+			}
+		})
+	}
+
+	source.SetError(context.DeadlineExceeded)
+	if authErr := m.checkPermission(context.Background(), &PrismClaims{TenantID: "tenant-c", Role: "staff"}, "invoice:read"); authErr == nil {
+		t.Error("SetError did not make the source fail")
+	}
+}
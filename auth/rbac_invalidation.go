@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/redis/go-redis/v9"
+)
+
+// RBACInvalidation adalah event perubahan role yang dikirim ke semua instance service.
+// TenantID dan Role kosong berarti semua: {"tenant_id":"t1"} membuang semua role tenant t1,
+// dan {} membuang seluruh cache.
+type RBACInvalidation struct {
+	TenantID string `json:"tenant_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// RBACInvalidationSource mengalirkan RBACInvalidation, misalnya lewat Redis pub/sub atau Consul KV.
+type RBACInvalidationSource interface {
+	SubscribeRBACInvalidations(ctx context.Context, handler func(RBACInvalidation)) error
+}
+
+// flightKey adalah key singleflight untuk satu role di satu tenant.
+func (k roleCacheKey) flightKey() string {
+	return k.tenantID + "\x00" + k.role
+}
+
+// InvalidateRole membuang izin role di tenant beserta semua izin efektif tenant tersebut.
+func (m *RBACMiddleware) InvalidateRole(tenantID, role string) {
+	m.Invalidate(RBACInvalidation{TenantID: tenantID, Role: role})
+}
+
+// InvalidateTenant membuang semua entri cache milik tenant.
+func (m *RBACMiddleware) InvalidateTenant(tenantID string) {
+	m.Invalidate(RBACInvalidation{TenantID: tenantID})
+}
+
+// InvalidateAll mengosongkan seluruh cache.
+func (m *RBACMiddleware) InvalidateAll() {
+	m.Invalidate(RBACInvalidation{})
+}
+
+// Invalidate menerapkan satu RBACInvalidation ke cache lokal. Request berikutnya untuk role
+// yang terdampak mengambil izin baru dari user-service.
+func (m *RBACMiddleware) Invalidate(event RBACInvalidation) {
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	m.cache.generation++
+
+	if event.TenantID == "" && event.Role == "" {
+		m.cache.permissions = make(map[roleCacheKey]cachedPermissions)
+		m.cache.effective = make(map[roleCacheKey]cachedEffective)
+		return
+	}
+	for key := range m.cache.permissions {
+		if (event.TenantID == "" || key.tenantID == event.TenantID) && (event.Role == "" || key.role == event.Role) {
+			delete(m.cache.permissions, key)
+			m.loads.Forget(key.flightKey())
+		}
+	}
+	// Izin efektif di-key per kombinasi role (termasuk role yang diwarisi), jadi semua entri
+	// tenant dibuang; membangunnya ulang murah karena izin role lain masih di cache.
+	for key := range m.cache.effective {
+		if event.TenantID == "" || key.tenantID == event.TenantID {
+			delete(m.cache.effective, key)
+		}
+	}
+}
+
+func (m *RBACMiddleware) listenInvalidations(ctx context.Context, source RBACInvalidationSource) {
+	for {
+		err := source.SubscribeRBACInvalidations(ctx, m.Invalidate)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Peringatan: langganan invalidasi RBAC terputus, mencoba lagi: %v", err)
+		// Event bisa terlewat selama terputus, jadi jangan percayai cache lama.
+		m.InvalidateAll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// DefaultRBACInvalidationChannel adalah channel Redis default untuk RBACInvalidation.
+const DefaultRBACInvalidationChannel = "prism:rbac:invalidations"
+
+// RedisRBACInvalidations mengirim dan menerima RBACInvalidation lewat Redis pub/sub.
+type RedisRBACInvalidations struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisRBACInvalidations membuat sumber invalidasi Redis. Channel kosong berarti
+// DefaultRBACInvalidationChannel.
+func NewRedisRBACInvalidations(client *redis.Client, channel string) *RedisRBACInvalidations {
+	if channel == "" {
+		channel = DefaultRBACInvalidationChannel
+	}
+	return &RedisRBACInvalidations{client: client, channel: channel}
+}
+
+// Publish mengirim event ke semua instance. Dipanggil oleh user-service setelah role diubah.
+func (r *RedisRBACInvalidations) Publish(ctx context.Context, event RBACInvalidation) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Publish(ctx, r.channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish RBAC invalidation: %w", err)
+	}
+	return nil
+}
+
+// SubscribeRBACInvalidations memanggil handler untuk setiap event sampai ctx selesai.
+func (r *RedisRBACInvalidations) SubscribeRBACInvalidations(ctx context.Context, handler func(RBACInvalidation)) error {
+	sub := r.client.Subscribe(ctx, r.channel)
+	defer func() { _ = sub.Close() }()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to '%s': %w", r.channel, err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var event RBACInvalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Peringatan: event invalidasi RBAC tidak valid diabaikan: %v", err)
+				continue
+			}
+			handler(event)
+		}
+	}
+}
+
+// DefaultRBACInvalidationKey adalah key Consul KV default untuk RBACInvalidation.
+const DefaultRBACInvalidationKey = "prism/rbac/invalidation"
+
+// ConsulRBACInvalidations mengirim dan menerima RBACInvalidation lewat satu key Consul KV.
+// Setiap penulisan key dianggap satu event; penerima memakai blocking query pada ModifyIndex.
+type ConsulRBACInvalidations struct {
+	client *consulapi.Client
+	key    string
+}
+
+// NewConsulRBACInvalidations membuat sumber invalidasi Consul. Key kosong berarti
+// DefaultRBACInvalidationKey.
+func NewConsulRBACInvalidations(client *consulapi.Client, key string) *ConsulRBACInvalidations {
+	if key == "" {
+		key = DefaultRBACInvalidationKey
+	}
+	return &ConsulRBACInvalidations{client: client, key: key}
+}
+
+// Publish menulis event ke key sehingga semua watcher menerimanya.
+func (c *ConsulRBACInvalidations) Publish(ctx context.Context, event RBACInvalidation) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	pair := &consulapi.KVPair{Key: c.key, Value: payload}
+	if _, err := c.client.KV().Put(pair, (&consulapi.WriteOptions{}).WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to write RBAC invalidation to '%s': %w", c.key, err)
+	}
+	return nil
+}
+
+// SubscribeRBACInvalidations menjalankan blocking query pada key dan memanggil handler setiap
+// kali key berubah, sampai ctx selesai. Nilai awal key saat berlangganan tidak dikirim.
+func (c *ConsulRBACInvalidations) SubscribeRBACInvalidations(ctx context.Context, handler func(RBACInvalidation)) error {
+	kv := c.client.KV()
+	var lastIndex, lastModify uint64
+	first := true
+	for {
+		opts := (&consulapi.QueryOptions{WaitIndex: lastIndex, WaitTime: 5 * time.Minute}).WithContext(ctx)
+		pair, meta, err := kv.Get(c.key, opts)
+		if err != nil {
+			return fmt.Errorf("failed to watch '%s': %w", c.key, err)
+		}
+		if meta.LastIndex < lastIndex {
+			// Index mundur (misal snapshot dipulihkan); mulai ulang dari awal.
+			lastIndex = 0
+			continue
+		}
+		lastIndex = meta.LastIndex
+
+		// Blocking query juga bisa kembali karena timeout atau perubahan lain; hanya
+		// ModifyIndex key yang menandai event baru.
+		var modify uint64
+		if pair != nil {
+			modify = pair.ModifyIndex
+		}
+		changed := modify != lastModify
+		lastModify = modify
+		if first {
+			first = false
+			continue
+		}
+		if !changed || pair == nil {
+			continue
+		}
+
+		var event RBACInvalidation
+		if err := json.Unmarshal(pair.Value, &event); err != nil {
+			log.Printf("Peringatan: event invalidasi RBAC tidak valid, membuang seluruh cache: %v", err)
+			event = RBACInvalidation{}
+		}
+		handler(event)
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRBACInvalidate(t *testing.T) {
+	memory := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}, "auditor": {"report:read"}})
+	source := newCountingSource(memory)
+	m := NewRBACMiddlewareWithSource(source, time.Hour)
+	defer m.Close()
+
+	warm := func() {
+		t.Helper()
+		for _, tenantID := range []string{"tenant-a", "tenant-b"} {
+			for _, role := range []string{"staff", "auditor"} {
+				allowed(m, tenantID, role, "invoice:read")
+			}
+		}
+	}
+	warm()
+	if got := source.count(); got != 4 {
+		t.Fatalf("fetches after warm-up = %d, want 4", got)
+	}
+
+	tests := []struct {
+		name        string
+		invalidate  func()
+		wantFetches int
+	}{
+		{"role in one tenant", func() { m.InvalidateRole("tenant-a", "staff") }, 1},
+		{"role in every tenant", func() { m.Invalidate(RBACInvalidation{Role: "auditor"}) }, 2},
+		{"one tenant", func() { m.InvalidateTenant("tenant-b") }, 2},
+		{"everything", m.InvalidateAll, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := source.count()
+			tt.invalidate()
+			warm()
+			if got := source.count() - before; got != tt.wantFetches {
+				t.Errorf("refetches = %d, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+
+	// Izin efektif ikut dibuang: perubahan role langsung berlaku setelah invalidasi.
+	memory.SetRole("staff", "invoice:read", "invoice:create")
+	if allowed(m, "tenant-a", "staff", "invoice:create") {
+		t.Fatal("cache changed before invalidation")
+	}
+	m.InvalidateRole("tenant-a", "staff")
+	if !allowed(m, "tenant-a", "staff", "invoice:create") {
+		t.Error("new permission not granted after InvalidateRole")
+	}
+}
+
+func TestRBACInvalidateDuringFetch(t *testing.T) {
+	memory := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}})
+	source := newCountingSource(memory)
+	m := NewRBACMiddlewareWithSource(source, time.Hour)
+	defer m.Close()
+
+	release := source.hold()
+	done := make(chan bool)
+	go func() { done <- allowed(m, "tenant-a", "staff", "invoice:read") }()
+	<-source.started
+	m.InvalidateRole("tenant-a", "staff")
+	release()
+	<-done
+
+	// Hasil fetch yang dimulai sebelum invalidasi tidak disimpan, jadi fetch berikutnya ke source.
+	allowed(m, "tenant-a", "staff", "invoice:read")
+	if got := source.count(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
+
+func TestRedisRBACInvalidations(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	invalidations := NewRedisRBACInvalidations(client, "")
+
+	source := newCountingSource(NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}}))
+	m := NewRBACMiddlewareWithSource(source, time.Hour, WithInvalidationSource(invalidations))
+	defer m.Close()
+	allowed(m, "tenant-a", "staff", "invoice:read")
+	allowed(m, "tenant-b", "staff", "invoice:read")
+
+	// Subscriber berjalan asinkron; publish ulang sampai event diterima.
+	ctx := context.Background()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(m.CachedTenants()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("invalidation not applied, cached tenants = %v", m.CachedTenants())
+		}
+		if err := invalidations.Publish(ctx, RBACInvalidation{TenantID: "tenant-a"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := m.CachedTenants(); len(got) != 1 || got[0] != "tenant-b" {
+		t.Errorf("cached tenants = %v, want [tenant-b]", got)
+	}
+
+	// Payload yang tidak valid diabaikan tanpa membuang cache.
+	if err := client.Publish(ctx, DefaultRBACInvalidationChannel, "not json").Err(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := m.CachedTenants(); len(got) != 1 {
+		t.Errorf("cached tenants after invalid payload = %v, want [tenant-b]", got)
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"sync"
+)
+
+// PermissionSource menyediakan izin setiap role per tenant untuk RBACMiddleware.
+// Implementasi default adalah user-service lewat gRPC (NewRBACMiddleware); FilePermissionSource
+// dan MemoryPermissionSource dipakai lewat NewRBACMiddlewareWithSource.
+type PermissionSource interface {
+	// PermissionsForRole mengembalikan izin role di tenant. Role yang tidak dikenal
+	// menghasilkan daftar kosong, bukan error.
+	PermissionsForRole(ctx context.Context, tenantID, role string) ([]string, error)
+}
+
+// RolePermissions memetakan nama role ke izinnya, misal {"staff": {"invoice:read"}}.
+type RolePermissions map[string][]string
+
+// StaticPermissions adalah definisi role statis. Roles berlaku untuk semua tenant; role di
+// Tenants menimpa role dengan nama sama untuk tenant tersebut.
+//
+//	roles:
+//	  admin: ["*"]
+//	  staff: ["invoice:read"]
+//	tenants:
+//	  tenant-a:
+//	    staff: ["invoice:read", "invoice:create"]
+type StaticPermissions struct {
+	Roles   RolePermissions            `json:"roles" yaml:"roles"`
+	Tenants map[string]RolePermissions `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+}
+
+func (p *StaticPermissions) lookup(tenantID, role string) []string {
+	if perms, ok := p.Tenants[tenantID][role]; ok {
+		return perms
+	}
+	return p.Roles[role]
+}
+
+// MemoryPermissionSource adalah PermissionSource di memori untuk pengujian. Perubahan lewat
+// SetRole/SetTenantRole dikirim sebagai RBACInvalidation ke RBACMiddleware yang memakainya;
+// karena listener berjalan asinkron, pengujian yang mengubah izin di tengah jalan sebaiknya
+// memakai cacheTTL 0 agar setiap pemeriksaan membaca source.
+type MemoryPermissionSource struct {
+	mu    sync.RWMutex
+	perms StaticPermissions
+	err   error
+	hub   invalidationHub
+}
+
+// NewMemoryPermissionSource membuat source dengan role yang berlaku untuk semua tenant.
+func NewMemoryPermissionSource(roles RolePermissions) *MemoryPermissionSource {
+	s := &MemoryPermissionSource{perms: StaticPermissions{Roles: make(RolePermissions)}}
+	for role, perms := range roles {
+		s.perms.Roles[role] = append([]string(nil), perms...)
+	}
+	return s
+}
+
+// SetRole mengganti izin role untuk semua tenant.
+func (s *MemoryPermissionSource) SetRole(role string, permissions ...string) {
+	s.mu.Lock()
+	s.perms.Roles[role] = permissions
+	s.mu.Unlock()
+	s.hub.publish(RBACInvalidation{Role: role})
+}
+
+// SetTenantRole mengganti izin role khusus untuk satu tenant.
+func (s *MemoryPermissionSource) SetTenantRole(tenantID, role string, permissions ...string) {
+	s.mu.Lock()
+	if s.perms.Tenants == nil {
+		s.perms.Tenants = make(map[string]RolePermissions)
+	}
+	if s.perms.Tenants[tenantID] == nil {
+		s.perms.Tenants[tenantID] = make(RolePermissions)
+	}
+	s.perms.Tenants[tenantID][role] = permissions
+	s.mu.Unlock()
+	s.hub.publish(RBACInvalidation{TenantID: tenantID, Role: role})
+}
+
+// SetError membuat PermissionsForRole gagal dengan err, untuk mensimulasikan user-service
+// yang tidak tersedia. nil mengembalikan perilaku normal.
+func (s *MemoryPermissionSource) SetError(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *MemoryPermissionSource) PermissionsForRole(ctx context.Context, tenantID, role string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.perms.lookup(tenantID, role), nil
+}
+
+// SubscribeRBACInvalidations mengimplementasikan RBACInvalidationSource.
+func (s *MemoryPermissionSource) SubscribeRBACInvalidations(ctx context.Context, handler func(RBACInvalidation)) error {
+	return s.hub.subscribe(ctx, handler)
+}
+
+// invalidationHub menyalurkan RBACInvalidation dari source lokal ke semua pelanggan.
+type invalidationHub struct {
+	mu       sync.Mutex
+	handlers map[int]func(RBACInvalidation)
+	next     int
+}
+
+// subscribe mendaftarkan handler sampai ctx selesai.
+func (h *invalidationHub) subscribe(ctx context.Context, handler func(RBACInvalidation)) error {
+	h.mu.Lock()
+	if h.handlers == nil {
+		h.handlers = make(map[int]func(RBACInvalidation))
+	}
+	id := h.next
+	h.next++
+	h.handlers[id] = handler
+	h.mu.Unlock()
+
+	<-ctx.Done()
+
+	h.mu.Lock()
+	delete(h.handlers, id)
+	h.mu.Unlock()
+	return ctx.Err()
+}
+
+func (h *invalidationHub) publish(event RBACInvalidation) {
+	h.mu.Lock()
+	handlers := make([]func(RBACInvalidation), 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler)
+	}
+	h.mu.Unlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
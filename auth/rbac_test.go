@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRBACTenantScopedPermissions(t *testing.T) {
+	source := NewMemoryPermissionSource(RolePermissions{
+		"staff":   {"invoice:read"},
+		"manager": {"invoice:approve"},
+		"admin":   {"*"},
+	})
+	source.SetTenantRole("tenant-a", "staff", "invoice:read", "invoice:create")
+	source.SetTenantRole("tenant-b", "admin", "report:read")
+	// Hierarki sama untuk semua tenant, tetapi izin role yang diwarisi dibaca per tenant.
+	m := NewRBACMiddlewareWithSource(source, time.Minute, WithRoleHierarchy(map[string][]string{"manager": {"staff"}}))
+	defer m.Close()
+
+	tests := []struct {
+		name       string
+		tenantID   string
+		role       string
+		permission string
+		want       bool
+	}{
+		{"tenant override grants", "tenant-a", "staff", "invoice:create", true},
+		{"other tenant uses global role", "tenant-b", "staff", "invoice:create", false},
+		{"inherits parent role of same tenant", "tenant-a", "manager", "invoice:create", true},
+		{"inherits global parent role", "tenant-b", "manager", "invoice:read", true},
+		{"does not inherit other tenant override", "tenant-b", "manager", "invoice:create", false},
+		{"own permission kept with hierarchy", "tenant-b", "manager", "invoice:approve", true},
+		{"global admin wildcard", "tenant-c", "admin", "user:delete", true},
+		{"tenant override narrows admin", "tenant-b", "admin", "user:delete", false},
+		{"narrowed admin keeps override permission", "tenant-b", "admin", "report:read", true},
+		{"unknown role", "tenant-a", "guest", "invoice:read", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &PrismClaims{TenantID: tt.tenantID, Role: tt.role}
+			authErr := m.checkPermission(context.Background(), claims, tt.permission)
+			if got := authErr == nil; got != tt.want {
+				t.Errorf("checkPermission(%s/%s, %q) allowed = %v, want %v (%v)", tt.tenantID, tt.role, tt.permission, got, tt.want, authErr)
+			}
+		})
+	}
+
+	if got, want := m.CachedTenants(), []string{"tenant-a", "tenant-b", "tenant-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CachedTenants() = %v, want %v", got, want)
+	}
+}
+
+// countingSource membungkus PermissionSource, menghitung fetch, dan bisa menahan fetch sampai
+// release dipanggil. Sengaja tidak mengimplementasikan RBACInvalidationSource.
+type countingSource struct {
+	source PermissionSource
+
+	mu      sync.Mutex
+	fetches int
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func newCountingSource(source PermissionSource) *countingSource {
+	return &countingSource{source: source, started: make(chan struct{}, 100)}
+}
+
+func (s *countingSource) PermissionsForRole(ctx context.Context, tenantID, role string) ([]string, error) {
+	s.mu.Lock()
+	s.fetches++
+	gate := s.gate
+	s.mu.Unlock()
+	s.started <- struct{}{}
+	if gate != nil {
+		<-gate
+	}
+	return s.source.PermissionsForRole(ctx, tenantID, role)
+}
+
+// hold membuat fetch berikutnya menunggu sampai fungsi yang dikembalikan dipanggil.
+func (s *countingSource) hold() (release func()) {
+	gate := make(chan struct{})
+	s.mu.Lock()
+	s.gate = gate
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.gate = nil
+		s.mu.Unlock()
+		close(gate)
+	}
+}
+
+func (s *countingSource) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func allowed(m *RBACMiddleware, tenantID, role, permission string) bool {
+	return m.checkPermission(context.Background(), &PrismClaims{TenantID: tenantID, Role: role}, permission) == nil
+}
+
+func TestRBACSingleFlight(t *testing.T) {
+	source := newCountingSource(NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}}))
+	m := NewRBACMiddlewareWithSource(source, time.Minute)
+	defer m.Close()
+
+	release := source.hold()
+	results := make(chan bool, 20)
+	for i := 0; i < cap(results); i++ {
+		go func() { results <- allowed(m, "tenant-a", "staff", "invoice:read") }()
+	}
+	<-source.started
+	release()
+	for i := 0; i < cap(results); i++ {
+		if !<-results {
+			t.Fatal("concurrent check denied")
+		}
+	}
+	if got := source.count(); got != 1 {
+		t.Errorf("fetches for concurrent misses = %d, want 1", got)
+	}
+
+	// Tenant lain tidak berbagi flight maupun cache.
+	if !allowed(m, "tenant-b", "staff", "invoice:read") {
+		t.Error("tenant-b denied")
+	}
+	if got := source.count(); got != 2 {
+		t.Errorf("fetches after second tenant = %d, want 2", got)
+	}
+}
+
+func TestRBACStaleWhileRevalidate(t *testing.T) {
+	memory := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}})
+	source := newCountingSource(memory)
+	m := NewRBACMiddlewareWithSource(source, 10*time.Millisecond, WithStaleWhileRevalidate(time.Hour))
+	defer m.Close()
+
+	if !allowed(m, "tenant-a", "staff", "invoice:read") {
+		t.Fatal("initial check denied")
+	}
+	<-source.started
+	memory.SetRole("staff", "invoice:create")
+	time.Sleep(20 * time.Millisecond)
+
+	// Entri basi tetap dipakai tanpa menunggu fetch yang sedang ditahan.
+	release := source.hold()
+	if !allowed(m, "tenant-a", "staff", "invoice:read") {
+		t.Error("stale entry not served while revalidating")
+	}
+	<-source.started
+	release()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !allowed(m, "tenant-a", "staff", "invoice:create") {
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh never replaced the stale entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if allowed(m, "tenant-a", "staff", "invoice:read") {
+		t.Error("old permission still granted after refresh")
+	}
+}
+
+func TestRBACStaleWindowExpired(t *testing.T) {
+	memory := NewMemoryPermissionSource(RolePermissions{"staff": {"invoice:read"}})
+	m := NewRBACMiddlewareWithSource(newCountingSource(memory), time.Millisecond, WithStaleWhileRevalidate(time.Millisecond))
+	defer m.Close()
+
+	if !allowed(m, "tenant-a", "staff", "invoice:read") {
+		t.Fatal("initial check denied")
+	}
+	time.Sleep(10 * time.Millisecond)
+	// Di luar window, fetch berjalan sinkron sehingga error source terlihat oleh request.
+	memory.SetError(errors.New("user-service down"))
+	authErr := m.checkPermission(context.Background(), &PrismClaims{TenantID: "tenant-a", Role: "staff"}, "invoice:read")
+	if authErr == nil || authErr.Status != http.StatusInternalServerError {
+		t.Errorf("checkPermission after stale window = %v, want 500", authErr)
+	}
+}
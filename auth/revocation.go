@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultMaxTokenLifetime adalah umur marker RevokeAllForUser/RevokeAllForTenant. Marker
+// harus hidup minimal selama token terpanjang yang mungkin masih beredar, yaitu refresh token;
+// setelah marker hilang, token yang diterbitkan sebelum pencabutan berlaku lagi. Jika
+// IssuerConfig.RefreshTTL dinaikkan, set MaxTokenLifetime store ke Issuer.MaxTokenLifetime().
+const DefaultMaxTokenLifetime = DefaultRefreshTokenTTL
+
+// RevocationChecker memeriksa apakah token sudah dicabut.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *PrismClaims) (bool, error)
+}
+
+// AnyRevoked menggabungkan beberapa RevocationChecker; token dianggap dicabut jika salah satu
+// checker mengatakan demikian. Checker nil diabaikan, dan nil dikembalikan jika tidak ada yang tersisa.
+func AnyRevoked(checkers ...RevocationChecker) RevocationChecker {
+	var active revocationCheckers
+	for _, c := range checkers {
+		if c != nil {
+			active = append(active, c)
+		}
+	}
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+	return active
+}
+
+type revocationCheckers []RevocationChecker
+
+func (cs revocationCheckers) IsRevoked(ctx context.Context, claims *PrismClaims) (bool, error) {
+	for _, c := range cs {
+		revoked, err := c.IsRevoked(ctx, claims)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	return false, nil
+}
+
+// RevocationStore menyimpan pencabutan token. RevokeJTI mencabut satu token sampai expiresAt;
+// RevokeAllForUser/RevokeAllForTenant mencabut semua token yang diterbitkan sebelum saat pemanggilan.
+type RevocationStore interface {
+	RevocationChecker
+	RevokeJTI(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeAllForUser(ctx context.Context, tenantID, userID string) error
+	RevokeAllForTenant(ctx context.Context, tenantID string) error
+}
+
+// RevocationFailurePolicy menentukan perilaku middleware ketika store pencabutan tidak bisa dihubungi.
+type RevocationFailurePolicy int
+
+const (
+	// FailClosed menolak request (500) jika status pencabutan tidak bisa dipastikan. Ini default.
+	FailClosed RevocationFailurePolicy = iota
+	// FailOpen meloloskan request dan mencatat peringatan; cocok untuk endpoint baca berisiko rendah.
+	FailOpen
+)
+
+// RevocationEvent dipublikasikan setiap kali token dicabut, agar cache lokal di instance lain
+// bisa langsung diperbarui.
+type RevocationEvent struct {
+	Type      string    `json:"type"` // "jti", "user", atau "tenant"
+	JTI       string    `json:"jti,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+const (
+	RevocationEventJTI    = "jti"
+	RevocationEventUser   = "user"
+	RevocationEventTenant = "tenant"
+)
+
+// issuedBefore memeriksa apakah token diterbitkan sebelum marker pencabutan massal (iat < marker).
+// Marker disimpan dengan presisi penuh, sedangkan iat hanya sepresisi jwt.TimePrecision (default
+// detik), sehingga keduanya dibandingkan pada presisi iat: token dari detik yang sama dengan
+// pencabutan, misalnya login ulang tepat setelah "logout dari semua perangkat", tetap berlaku.
+// Token tanpa iat dianggap dicabut karena umurnya tidak bisa dipastikan.
+func issuedBefore(claims *PrismClaims, marker time.Time) bool {
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return claims.IssuedAt.Before(marker.Truncate(jwt.TimePrecision))
+}
+
+func userMarkerKey(tenantID, userID string) string {
+	return tenantID + ":" + userID
+}
+
+// MemoryRevocationStore adalah RevocationStore di memori untuk unit test dan pengembangan lokal.
+type MemoryRevocationStore struct {
+	mu               sync.Mutex
+	jtis             map[string]time.Time
+	users            map[string]time.Time
+	tenants          map[string]time.Time
+	maxTokenLifetime time.Duration
+	now              func() time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		jtis:             make(map[string]time.Time),
+		users:            make(map[string]time.Time),
+		tenants:          make(map[string]time.Time),
+		maxTokenLifetime: DefaultMaxTokenLifetime,
+		now:              time.Now,
+	}
+}
+
+// SetMaxTokenLifetime mengatur umur marker pencabutan massal, misalnya Issuer.MaxTokenLifetime().
+func (s *MemoryRevocationStore) SetMaxTokenLifetime(lifetime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxTokenLifetime = lifetime
+}
+
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, claims *PrismClaims) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+
+	if exp, ok := s.jtis[claims.ID]; ok {
+		if now.Before(exp) {
+			return true, nil
+		}
+		delete(s.jtis, claims.ID)
+	}
+	if marker, ok := s.users[userMarkerKey(claims.TenantID, claims.Subject)]; ok &&
+		now.Before(marker.Add(s.maxTokenLifetime)) && issuedBefore(claims, marker) {
+		return true, nil
+	}
+	if marker, ok := s.tenants[claims.TenantID]; ok &&
+		now.Before(marker.Add(s.maxTokenLifetime)) && issuedBefore(claims, marker) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *MemoryRevocationStore) RevokeJTI(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jtis[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokeAllForUser(_ context.Context, tenantID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userMarkerKey(tenantID, userID)] = s.now()
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokeAllForTenant(_ context.Context, tenantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[tenantID] = s.now()
+	return nil
+}
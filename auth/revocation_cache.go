@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevocationEventSource adalah store yang bisa mengalirkan RevocationEvent, misalnya
+// RedisRevocationStore melalui pub/sub.
+type RevocationEventSource interface {
+	SubscribeRevocations(ctx context.Context, handler func(RevocationEvent)) error
+}
+
+// CachedRevocationOptions mengatur ukuran dan umur cache lokal.
+type CachedRevocationOptions struct {
+	// NegativeTTL adalah berapa lama hasil "tidak dicabut" dipercaya tanpa bertanya ke backend.
+	// Ini juga batas atas keterlambatan jika event pub/sub terlewat. Default 30 detik.
+	NegativeTTL time.Duration
+	// Capacity adalah jumlah maksimum jti di LRU dan ukuran dasar bloom filter. Default 100000.
+	Capacity int
+	// MaxTokenLifetime adalah umur marker user/tenant yang dipelajari dari event. Default
+	// DefaultMaxTokenLifetime; harus minimal Issuer.MaxTokenLifetime().
+	MaxTokenLifetime time.Duration
+}
+
+// CachedRevocationStore adalah front cache lokal di depan RevocationStore lain.
+// Hasil negatif disimpan di LRU dengan TTL pendek; jti yang diketahui dicabut dicatat di
+// bloom filter sehingga selalu dikonfirmasi ke backend. Event pub/sub dari backend
+// (jika backend mengimplementasikan RevocationEventSource) membuang entri LRU yang basi.
+type CachedRevocationStore struct {
+	backend RevocationStore
+	opts    CachedRevocationOptions
+
+	mu      sync.Mutex
+	lru     *lruCache
+	bloom   *bloomFilter
+	markers map[string]time.Time // "user:<tid>:<uid>" / "tenant:<tid>" -> revoked-before
+
+	cancel context.CancelFunc
+}
+
+// NewCachedRevocationStore membungkus backend dengan cache lokal. Jika backend mendukung
+// RevocationEventSource, listener pub/sub dijalankan di background sampai Close dipanggil.
+func NewCachedRevocationStore(backend RevocationStore, opts CachedRevocationOptions) *CachedRevocationStore {
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = 30 * time.Second
+	}
+	if opts.Capacity <= 0 {
+		opts.Capacity = 100000
+	}
+	if opts.MaxTokenLifetime <= 0 {
+		opts.MaxTokenLifetime = DefaultMaxTokenLifetime
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &CachedRevocationStore{
+		backend: backend,
+		opts:    opts,
+		lru:     newLRUCache(opts.Capacity),
+		bloom:   newBloomFilter(opts.Capacity),
+		markers: make(map[string]time.Time),
+		cancel:  cancel,
+	}
+	if source, ok := backend.(RevocationEventSource); ok {
+		go s.listen(ctx, source)
+	}
+	return s
+}
+
+// Close menghentikan listener pub/sub.
+func (s *CachedRevocationStore) Close() {
+	s.cancel()
+}
+
+func (s *CachedRevocationStore) listen(ctx context.Context, source RevocationEventSource) {
+	for {
+		err := source.SubscribeRevocations(ctx, s.apply)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Peringatan: langganan event pencabutan terputus, mencoba lagi: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// apply memperbarui cache lokal berdasarkan satu RevocationEvent.
+func (s *CachedRevocationStore) apply(event RevocationEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch event.Type {
+	case RevocationEventJTI:
+		s.bloom.add(event.JTI)
+		s.lru.remove(event.JTI)
+	case RevocationEventUser:
+		s.markers["user:"+userMarkerKey(event.TenantID, event.UserID)] = event.RevokedAt
+	case RevocationEventTenant:
+		s.markers["tenant:"+event.TenantID] = event.RevokedAt
+	}
+}
+
+// revokedByMarker memeriksa marker user/tenant yang diketahui secara lokal. Harus dipanggil dengan mu terkunci.
+func (s *CachedRevocationStore) revokedByMarker(claims *PrismClaims) bool {
+	for _, key := range []string{"user:" + userMarkerKey(claims.TenantID, claims.Subject), "tenant:" + claims.TenantID} {
+		marker, ok := s.markers[key]
+		if !ok {
+			continue
+		}
+		if time.Since(marker) > s.opts.MaxTokenLifetime {
+			delete(s.markers, key)
+			continue
+		}
+		if issuedBefore(claims, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *CachedRevocationStore) IsRevoked(ctx context.Context, claims *PrismClaims) (bool, error) {
+	s.mu.Lock()
+	if s.revokedByMarker(claims) {
+		s.mu.Unlock()
+		return true, nil
+	}
+	// Jti yang mungkin dicabut (bloom positif) selalu dikonfirmasi ke backend.
+	if !s.bloom.mayContain(claims.ID) {
+		if expiresAt, ok := s.lru.get(claims.ID); ok && time.Now().Before(expiresAt) {
+			s.mu.Unlock()
+			return false, nil
+		}
+	}
+	s.mu.Unlock()
+
+	revoked, err := s.backend.IsRevoked(ctx, claims)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if revoked {
+		s.bloom.add(claims.ID)
+		s.lru.remove(claims.ID)
+	} else {
+		s.lru.put(claims.ID, time.Now().Add(s.opts.NegativeTTL))
+	}
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+func (s *CachedRevocationStore) RevokeJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := s.backend.RevokeJTI(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	s.apply(RevocationEvent{Type: RevocationEventJTI, JTI: jti, RevokedAt: time.Now(), ExpiresAt: expiresAt})
+	return nil
+}
+
+func (s *CachedRevocationStore) RevokeAllForUser(ctx context.Context, tenantID, userID string) error {
+	if err := s.backend.RevokeAllForUser(ctx, tenantID, userID); err != nil {
+		return err
+	}
+	s.apply(RevocationEvent{Type: RevocationEventUser, TenantID: tenantID, UserID: userID, RevokedAt: time.Now()})
+	return nil
+}
+
+func (s *CachedRevocationStore) RevokeAllForTenant(ctx context.Context, tenantID string) error {
+	if err := s.backend.RevokeAllForTenant(ctx, tenantID); err != nil {
+		return err
+	}
+	s.apply(RevocationEvent{Type: RevocationEventTenant, TenantID: tenantID, RevokedAt: time.Now()})
+	return nil
+}
+
+// lruCache adalah LRU sederhana jti -> waktu kedaluwarsa entri. Tidak thread-safe.
+type lruCache struct {
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{capacity: capacity, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string) (time.Time, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).expiresAt, true
+}
+
+func (c *lruCache) put(key string, expiresAt time.Time) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, expiresAt: expiresAt})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// bloomFilter mencatat jti yang diketahui dicabut. Karena bloom filter tidak bisa menghapus,
+// filter di-reset ketika jumlah entri melewati kapasitas; akibatnya hanya lebih banyak
+// pemeriksaan ke backend, bukan hasil yang salah.
+type bloomFilter struct {
+	bits     []uint64
+	k        uint64
+	count    int
+	capacity int
+}
+
+func newBloomFilter(capacity int) *bloomFilter {
+	// ~10 bit per elemen dan 7 hash memberi false-positive rate sekitar 1%.
+	m := capacity * 10
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), k: 7, capacity: capacity}
+}
+
+func (b *bloomFilter) hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := (h1 >> 33) | (h1 << 31) | 1
+	return h1, h2
+}
+
+func (b *bloomFilter) add(key string) {
+	if b.count >= b.capacity {
+		for i := range b.bits {
+			b.bits[i] = 0
+		}
+		b.count = 0
+	}
+	h1, h2 := b.hashes(key)
+	m := uint64(len(b.bits) * 64)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.count++
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	h1, h2 := b.hashes(key)
+	m := uint64(len(b.bits) * 64)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRevocationOptions mengatur layout key dan channel RedisRevocationStore.
+type RedisRevocationOptions struct {
+	// KeyPrefix untuk key pencabutan. Default kosong agar kompatibel dengan auth service
+	// yang menulis `SET <jti>` langsung.
+	KeyPrefix string
+	// MaxTokenLifetime adalah TTL marker pencabutan massal. Default DefaultMaxTokenLifetime;
+	// harus minimal Issuer.MaxTokenLifetime().
+	MaxTokenLifetime time.Duration
+	// Channel pub/sub untuk RevocationEvent. Default "prism:revocations".
+	Channel string
+}
+
+// RedisRevocationStore menyimpan pencabutan di Redis dan mempublikasikan RevocationEvent.
+type RedisRevocationStore struct {
+	client *redis.Client
+	opts   RedisRevocationOptions
+}
+
+func NewRedisRevocationStore(client *redis.Client, opts RedisRevocationOptions) *RedisRevocationStore {
+	if opts.MaxTokenLifetime <= 0 {
+		opts.MaxTokenLifetime = DefaultMaxTokenLifetime
+	}
+	if opts.Channel == "" {
+		opts.Channel = "prism:revocations"
+	}
+	return &RedisRevocationStore{client: client, opts: opts}
+}
+
+func (s *RedisRevocationStore) jtiKey(jti string) string {
+	return s.opts.KeyPrefix + jti
+}
+
+func (s *RedisRevocationStore) userKey(tenantID, userID string) string {
+	return s.opts.KeyPrefix + "revoked:user:" + userMarkerKey(tenantID, userID)
+}
+
+func (s *RedisRevocationStore) tenantKey(tenantID string) string {
+	return s.opts.KeyPrefix + "revoked:tenant:" + tenantID
+}
+
+// IsRevoked memeriksa jti, marker user, dan marker tenant dalam satu round-trip MGET.
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, claims *PrismClaims) (bool, error) {
+	values, err := s.client.MGet(ctx,
+		s.jtiKey(claims.ID),
+		s.userKey(claims.TenantID, claims.Subject),
+		s.tenantKey(claims.TenantID),
+	).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	if values[0] != nil {
+		return true, nil
+	}
+	for _, v := range values[1:] {
+		marker, ok := parseMarker(v)
+		if ok && issuedBefore(claims, marker) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func parseMarker(v interface{}) (time.Time, bool) {
+	str, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if marker, err := time.Parse(time.RFC3339Nano, str); err == nil {
+		return marker, true
+	}
+	// Marker lama disimpan sebagai detik Unix.
+	unix, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unix, 0), true
+}
+
+// RevokeJTI mencabut satu token. TTL key mengikuti sisa umur token.
+func (s *RedisRevocationStore) RevokeJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil // Token sudah kedaluwarsa; tidak perlu dicabut.
+	}
+	if err := s.client.Set(ctx, s.jtiKey(jti), "revoked", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token '%s': %w", jti, err)
+	}
+	return s.publish(ctx, RevocationEvent{Type: RevocationEventJTI, JTI: jti, RevokedAt: time.Now(), ExpiresAt: expiresAt})
+}
+
+// RevokeAllForUser mencabut semua token user di tenant yang diterbitkan sampai saat ini.
+func (s *RedisRevocationStore) RevokeAllForUser(ctx context.Context, tenantID, userID string) error {
+	now := time.Now()
+	if err := s.client.Set(ctx, s.userKey(tenantID, userID), now.Format(time.RFC3339Nano), s.opts.MaxTokenLifetime).Err(); err != nil {
+		return fmt.Errorf("failed to revoke tokens for user '%s': %w", userID, err)
+	}
+	return s.publish(ctx, RevocationEvent{
+		Type: RevocationEventUser, TenantID: tenantID, UserID: userID,
+		RevokedAt: now, ExpiresAt: now.Add(s.opts.MaxTokenLifetime),
+	})
+}
+
+// RevokeAllForTenant mencabut semua token di tenant yang diterbitkan sampai saat ini.
+func (s *RedisRevocationStore) RevokeAllForTenant(ctx context.Context, tenantID string) error {
+	now := time.Now()
+	if err := s.client.Set(ctx, s.tenantKey(tenantID), now.Format(time.RFC3339Nano), s.opts.MaxTokenLifetime).Err(); err != nil {
+		return fmt.Errorf("failed to revoke tokens for tenant '%s': %w", tenantID, err)
+	}
+	return s.publish(ctx, RevocationEvent{
+		Type: RevocationEventTenant, TenantID: tenantID,
+		RevokedAt: now, ExpiresAt: now.Add(s.opts.MaxTokenLifetime),
+	})
+}
+
+func (s *RedisRevocationStore) publish(ctx context.Context, event RevocationEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Publish(ctx, s.opts.Channel, payload).Err(); err != nil {
+		// Pencabutan sudah tersimpan; cache lokal akan menyusul setelah TTL-nya habis.
+		log.Printf("Peringatan: gagal mempublikasikan event pencabutan: %v", err)
+	}
+	return nil
+}
+
+// SubscribeRevocations memanggil handler untuk setiap RevocationEvent sampai ctx selesai.
+func (s *RedisRevocationStore) SubscribeRevocations(ctx context.Context, handler func(RevocationEvent)) error {
+	sub := s.client.Subscribe(ctx, s.opts.Channel)
+	defer func() { _ = sub.Close() }()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to '%s': %w", s.opts.Channel, err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var event RevocationEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Peringatan: event pencabutan tidak valid diabaikan: %v", err)
+				continue
+			}
+			handler(event)
+		}
+	}
+}
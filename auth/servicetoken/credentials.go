@@ -0,0 +1,95 @@
+package servicetoken
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TokenSource menyimpan token service untuk satu audience dan scope, dan mencetak token baru
+// saat 80% umurnya terlewati, sehingga pemanggil tidak pernah mengirim token yang hampir kedaluwarsa.
+// Aman dipakai bersamaan.
+type TokenSource struct {
+	minter   *Minter
+	audience string
+	scopes   []string
+
+	mu      sync.Mutex
+	token   string
+	renewAt time.Time
+}
+
+// NewTokenSource membuat TokenSource untuk memanggil service audience dengan scopes.
+func NewTokenSource(minter *Minter, audience string, scopes ...string) *TokenSource {
+	return &TokenSource{minter: minter, audience: audience, scopes: scopes}
+}
+
+// Token mengembalikan token yang di-cache atau mencetak token baru.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.minter.now()
+	if s.token != "" && now.Before(s.renewAt) {
+		return s.token, nil
+	}
+	token, expiresAt, err := s.minter.Mint(ctx, s.audience, s.scopes...)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.renewAt = now.Add(expiresAt.Sub(now) * 4 / 5)
+	return token, nil
+}
+
+// PerRPCCredentials mengembalikan kredensial gRPC yang mengirim token di metadata MetadataKey,
+// untuk dipakai dengan grpc.WithPerRPCCredentials. Jika requireTLS true, gRPC menolak
+// mengirim token lewat koneksi tanpa TLS.
+func (s *TokenSource) PerRPCCredentials(requireTLS bool) credentials.PerRPCCredentials {
+	return &perRPCCredentials{source: s, requireTLS: requireTLS}
+}
+
+type perRPCCredentials struct {
+	source     *TokenSource
+	requireTLS bool
+}
+
+func (c *perRPCCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{MetadataKey: token}, nil
+}
+
+func (c *perRPCCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// RoundTripper membungkus base (nil berarti http.DefaultTransport) agar setiap request
+// membawa token di header Header.
+func (s *TokenSource) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &roundTripper{source: s, base: base}
+}
+
+type roundTripper struct {
+	source *TokenSource
+	base   http.RoundTripper
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	// RoundTripper tidak boleh mengubah request asli.
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, token)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,137 @@
+package servicetoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+)
+
+// countingKeys menghitung berapa kali Minter meminta kunci, yaitu jumlah token yang dicetak.
+type countingKeys struct {
+	keys auth.SigningKeyProvider
+
+	mu    sync.Mutex
+	mints int
+	err   error
+}
+
+func (k *countingKeys) SigningKey(ctx context.Context) (*auth.SigningKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.mints++
+	if k.err != nil {
+		return nil, k.err
+	}
+	return k.keys.SigningKey(ctx)
+}
+
+func (k *countingKeys) count() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.mints
+}
+
+func TestTokenSource(t *testing.T) {
+	keys := newTestKeySet(t, "svc-1")
+	counting := &countingKeys{keys: keys}
+	minter := newTestMinter(t, counting, testCaller, 10*time.Minute)
+	now := time.Now()
+	minter.now = func() time.Time { return now }
+	source := NewTokenSource(minter, testAudience, "user:read")
+	verifier := newTestVerifier(t, VerifierConfig{Keys: keys})
+
+	first, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	claims, err := verifier.Verify(context.Background(), first)
+	if err != nil || !claims.HasScope("user:read") {
+		t.Fatalf("minted token = %+v, %v; want valid token with user:read", claims, err)
+	}
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		wantNew   bool
+		wantMints int
+	}{
+		{"cached", time.Minute, false, 1},
+		{"cached before 80% of lifetime", 6 * time.Minute, false, 1},
+		{"renewed at 80% of lifetime", time.Minute, true, 2},
+		{"renewed token cached", time.Minute, false, 2},
+	}
+	previous := first
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Token: %v", step.name, err)
+		}
+		if renewed := token != previous; renewed != step.wantNew {
+			t.Errorf("%s: renewed = %v, want %v", step.name, renewed, step.wantNew)
+		}
+		if got := counting.count(); got != step.wantMints {
+			t.Errorf("%s: mints = %d, want %d", step.name, got, step.wantMints)
+		}
+		previous = token
+	}
+
+	// Gagal mencetak token baru tidak mengembalikan token lama yang sudah harus diperbarui.
+	counting.mu.Lock()
+	counting.err = errors.New("vault unavailable")
+	counting.mu.Unlock()
+	now = now.Add(10 * time.Minute)
+	if token, err := source.Token(context.Background()); err == nil {
+		t.Errorf("Token with failing minter = %q, want error", token)
+	}
+}
+
+func TestTokenSourceTransports(t *testing.T) {
+	keys := newTestKeySet(t, "svc-1")
+	source := NewTokenSource(newTestMinter(t, keys, testCaller, 0), testAudience, "user:read")
+	verifier := newTestVerifier(t, VerifierConfig{Keys: keys})
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(Header)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: source.RoundTripper(nil)}
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := verifier.Verify(context.Background(), received); err != nil {
+		t.Errorf("token sent over HTTP: %v", err)
+	}
+	if req.Header.Get(Header) != "" {
+		t.Error("RoundTripper modified the original request")
+	}
+
+	for _, requireTLS := range []bool{true, false} {
+		creds := source.PerRPCCredentials(requireTLS)
+		if creds.RequireTransportSecurity() != requireTLS {
+			t.Errorf("RequireTransportSecurity = %v, want %v", creds.RequireTransportSecurity(), requireTLS)
+		}
+		md, err := creds.GetRequestMetadata(context.Background())
+		if err != nil {
+			t.Fatalf("GetRequestMetadata: %v", err)
+		}
+		if md[MetadataKey] != received {
+			t.Errorf("gRPC metadata token differs from cached HTTP token")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package servicetoken menerbitkan dan memverifikasi token berumur pendek untuk panggilan
+// internal antar-service Prism.
+//
+// Service pemanggil mencetak token dengan Minter (sub = nama service, aud = service tujuan,
+// scopes = aksi yang diminta) menggunakan kunci dari Vault, lalu memasangnya lewat
+// TokenSource sebagai kredensial gRPC atau http.RoundTripper. Service tujuan memverifikasi
+// audience dan scope dengan Verifier. Token service dikirim di header "X-Service-Token"
+// sehingga token pengguna di "Authorization" tetap bisa diteruskan bersamaan.
+package servicetoken
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultIssuer adalah claim iss untuk token service.
+	DefaultIssuer = "prism-service-token"
+	// DefaultTTL adalah umur token service jika MinterConfig.TTL kosong.
+	DefaultTTL = 5 * time.Minute
+	// Header adalah header HTTP yang membawa token service, sama dengan default
+	// auth.ServiceTokenAuthenticator.
+	Header = "X-Service-Token"
+	// MetadataKey adalah key metadata gRPC yang membawa token service.
+	MetadataKey = "x-service-token"
+)
+
+// MinterConfig mengatur Minter.
+type MinterConfig struct {
+	// Service adalah nama service ini, misalnya "prism-invoice-service". Menjadi claim sub.
+	Service string
+	// Keys adalah sumber kunci penandatangan, biasanya hasil NewKeySetFromVault.
+	Keys auth.SigningKeyProvider
+	// Issuer default DefaultIssuer.
+	Issuer string
+	// TTL default DefaultTTL.
+	TTL time.Duration
+}
+
+// Minter mencetak token service untuk satu service pemanggil.
+type Minter struct {
+	cfg MinterConfig
+	now func() time.Time
+}
+
+// NewMinter membuat Minter.
+func NewMinter(cfg MinterConfig) (*Minter, error) {
+	if cfg.Service == "" {
+		return nil, fmt.Errorf("service token minter requires a service name")
+	}
+	if cfg.Keys == nil {
+		return nil, fmt.Errorf("service token minter requires a signing key provider")
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultIssuer
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	return &Minter{cfg: cfg, now: time.Now}, nil
+}
+
+// Mint mencetak token untuk audience (nama service tujuan) dengan scopes yang diminta.
+func (m *Minter) Mint(ctx context.Context, audience string, scopes ...string) (string, time.Time, error) {
+	if audience == "" {
+		return "", time.Time{}, fmt.Errorf("service token requires an audience")
+	}
+	key, err := m.cfg.Keys.SigningKey(ctx)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get signing key: %w", err)
+	}
+
+	now := m.now()
+	expiresAt := now.Add(m.cfg.TTL)
+	claims := auth.PrismClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.cfg.Issuer,
+			Subject:   m.cfg.Service,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
+		Scopes:   scopes,
+		TokenUse: auth.TokenUseService,
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.Key)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign service token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// SecretReader membaca satu field rahasia, misalnya *client.VaultClient.
+type SecretReader interface {
+	ReadSecret(path, key string) (string, error)
+}
+
+// NewKeySetFromVault membaca kunci privat PEM dari Vault (path dan field) dan membungkusnya
+// dalam auth.KeySet dengan kid, sehingga bisa dipakai Minter maupun Verifier.
+func NewKeySetFromVault(vault SecretReader, path, field, kid string) (*auth.KeySet, error) {
+	pemData, err := vault.ReadSecret(path, field)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service token key from Vault: %w", err)
+	}
+	key, err := auth.NewSigningKeyFromPEM(kid, []byte(pemData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid service token key at '%s': %w", path, err)
+	}
+	return auth.NewKeySet(key)
+}
@@ -0,0 +1,121 @@
+package servicetoken
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+)
+
+// newTestKeyPEM membuat kunci privat EC P-256 dalam format PEM, seperti yang disimpan di Vault.
+func newTestKeyPEM(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+func newTestKeySet(t *testing.T, kid string) *auth.KeySet {
+	t.Helper()
+	key, err := auth.NewSigningKeyFromPEM(kid, []byte(newTestKeyPEM(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeySet(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func newTestMinter(t *testing.T, keys auth.SigningKeyProvider, service string, ttl time.Duration) *Minter {
+	t.Helper()
+	minter, err := NewMinter(MinterConfig{Service: service, Keys: keys, TTL: ttl})
+	if err != nil {
+		t.Fatalf("NewMinter: %v", err)
+	}
+	return minter
+}
+
+// fakeVault adalah SecretReader dengan isi tetap.
+type fakeVault map[string]string
+
+func (v fakeVault) ReadSecret(path, key string) (string, error) {
+	value, ok := v[path+"#"+key]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return value, nil
+}
+
+func TestNewKeySetFromVault(t *testing.T) {
+	vault := fakeVault{
+		"secret/prism/service-token#private_key": newTestKeyPEM(t),
+		"secret/prism/broken#private_key":        "not a pem",
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"valid key", "secret/prism/service-token", false},
+		{"invalid key", "secret/prism/broken", true},
+		{"missing secret", "secret/prism/missing", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeySetFromVault(vault, tt.path, "private_key", "svc-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeySetFromVault error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			key, err := keys.SigningKey(context.Background())
+			if err != nil || key.ID != "svc-1" || key.Method.Alg() != "ES256" {
+				t.Errorf("SigningKey = %+v, %v; want ES256 key svc-1", key, err)
+			}
+		})
+	}
+}
+
+func TestNewMinter(t *testing.T) {
+	keys := newTestKeySet(t, "svc-1")
+	if _, err := NewMinter(MinterConfig{Keys: keys}); err == nil {
+		t.Error("NewMinter without service returned nil error")
+	}
+	if _, err := NewMinter(MinterConfig{Service: "prism-invoice-service"}); err == nil {
+		t.Error("NewMinter without keys returned nil error")
+	}
+
+	minter := newTestMinter(t, keys, "prism-invoice-service", 0)
+	if minter.cfg.Issuer != DefaultIssuer || minter.cfg.TTL != DefaultTTL {
+		t.Errorf("defaults = %q/%v, want %q/%v", minter.cfg.Issuer, minter.cfg.TTL, DefaultIssuer, DefaultTTL)
+	}
+	if _, _, err := minter.Mint(context.Background(), ""); err == nil {
+		t.Error("Mint without audience returned nil error")
+	}
+
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	minter.now = func() time.Time { return now }
+	_, expiresAt, err := minter.Mint(context.Background(), "prism-user-service", "user:read")
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if want := now.Add(DefaultTTL); !expiresAt.Equal(want) {
+		t.Errorf("expiresAt = %v, want %v", expiresAt, want)
+	}
+}
@@ -0,0 +1,231 @@
+package servicetoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxTTL adalah umur maksimum token yang diterima Verifier jika VerifierConfig.MaxTTL kosong.
+const DefaultMaxTTL = 15 * time.Minute
+
+var (
+	// ErrInvalidToken dikembalikan untuk token service yang rusak, kedaluwarsa, atau salah audience.
+	ErrInvalidToken = errors.New("invalid service token")
+	// ErrServiceNotAllowed dikembalikan jika service pemanggil tidak ada di AllowedServices.
+	ErrServiceNotAllowed = errors.New("calling service not allowed")
+	// ErrInsufficientScope dikembalikan jika token tidak membawa semua scope yang dibutuhkan.
+	ErrInsufficientScope = errors.New("insufficient service token scope")
+)
+
+// VerifierConfig mengatur Verifier.
+type VerifierConfig struct {
+	// Audience adalah nama service ini; token untuk service lain ditolak. Wajib.
+	Audience string
+	// Keys memverifikasi tanda tangan, misalnya KeySet dari NewKeySetFromVault atau JWKS.
+	Keys auth.KeyProvider
+	// Issuer default DefaultIssuer.
+	Issuer string
+	// Leeway untuk selisih jam antar-server. Default 30 detik.
+	Leeway time.Duration
+	// MaxTTL menolak token yang umurnya (exp - iat) lebih panjang. Default DefaultMaxTTL.
+	MaxTTL time.Duration
+	// AllowedServices (opsional) membatasi service pemanggil yang diterima.
+	AllowedServices []string
+}
+
+// Verifier memvalidasi token service. Verifier juga mengimplementasikan
+// auth.ServiceTokenVerifier sehingga bisa dipakai di auth.ServiceTokenAuthenticator.
+type Verifier struct {
+	cfg    VerifierConfig
+	parser *jwt.Parser
+}
+
+// NewVerifier membuat Verifier.
+func NewVerifier(cfg VerifierConfig) (*Verifier, error) {
+	if cfg.Audience == "" {
+		return nil, fmt.Errorf("service token verifier requires an audience")
+	}
+	if cfg.Keys == nil {
+		return nil, fmt.Errorf("service token verifier requires a key provider")
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultIssuer
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = 30 * time.Second
+	}
+	if cfg.MaxTTL <= 0 {
+		cfg.MaxTTL = DefaultMaxTTL
+	}
+	parser := jwt.NewParser(
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.Leeway),
+	)
+	return &Verifier{cfg: cfg, parser: parser}, nil
+}
+
+// Verify memvalidasi token dan mengembalikan claims-nya.
+func (v *Verifier) Verify(ctx context.Context, token string) (*auth.PrismClaims, error) {
+	claims := &auth.PrismClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return v.cfg.Keys.VerificationKey(ctx, t)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.TokenUse != auth.TokenUseService || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: not a service token", ErrInvalidToken)
+	}
+	if claims.IssuedAt == nil || claims.ExpiresAt.Sub(claims.IssuedAt.Time) > v.cfg.MaxTTL {
+		return nil, fmt.Errorf("%w: lifetime exceeds %s", ErrInvalidToken, v.cfg.MaxTTL)
+	}
+	if len(v.cfg.AllowedServices) > 0 && !contains(v.cfg.AllowedServices, claims.Subject) {
+		return nil, fmt.Errorf("%w: '%s'", ErrServiceNotAllowed, claims.Subject)
+	}
+	return claims, nil
+}
+
+// VerifyServiceToken mengimplementasikan auth.ServiceTokenVerifier. Identitas yang dihasilkan
+// memakai UserID "service:<nama>" dan Role "service", sama seperti auth.StaticServiceTokens.
+func (v *Verifier) VerifyServiceToken(ctx context.Context, token string) (*auth.Identity, error) {
+	claims, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return newIdentity(claims), nil
+}
+
+func newIdentity(claims *auth.PrismClaims) *auth.Identity {
+	return &auth.Identity{
+		UserID:     "service:" + claims.Subject,
+		Role:       "service",
+		AuthMethod: auth.AuthMethodServiceToken,
+		Claims:     claims,
+	}
+}
+
+// Authenticator mengembalikan auth.Authenticator yang mewajibkan semua requiredScopes, untuk
+// digabung dengan metode lain di auth.Chain. Request tanpa header Header dilewatkan ke
+// authenticator berikutnya.
+func (v *Verifier) Authenticator(requiredScopes ...string) auth.Authenticator {
+	return &authenticator{verifier: v, scopes: requiredScopes}
+}
+
+// Middleware mewajibkan token service yang valid dengan semua requiredScopes.
+// Token tidak ada atau tidak valid dijawab 401; scope kurang dijawab 403.
+func (v *Verifier) Middleware(requiredScopes ...string) gin.HandlerFunc {
+	return auth.Chain(v.Authenticator(requiredScopes...))
+}
+
+type authenticator struct {
+	verifier *Verifier
+	scopes   []string
+}
+
+func (a *authenticator) Name() string {
+	return auth.AuthMethodServiceToken
+}
+
+func (a *authenticator) Authenticate(c *gin.Context) (*auth.Identity, *auth.AuthError) {
+	token := c.GetHeader(Header)
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := a.verifier.Verify(c.Request.Context(), token)
+	if err != nil {
+		return nil, &auth.AuthError{Status: http.StatusUnauthorized, Message: "Invalid service token", Err: err}
+	}
+	if missing := missingScopes(claims, a.scopes); len(missing) > 0 {
+		return nil, &auth.AuthError{
+			Status:  http.StatusForbidden,
+			Message: fmt.Sprintf("Service token missing required scopes: %s", strings.Join(missing, ", ")),
+			Err:     ErrInsufficientScope,
+		}
+	}
+	return newIdentity(claims), nil
+}
+
+// UnaryServerInterceptor mewajibkan token service di metadata MetadataKey untuk setiap unary
+// RPC, dengan scope per method dari methodScopes (nama method lengkap -> scope). Method yang
+// tidak ada di map hanya membutuhkan token yang valid.
+func (v *Verifier) UnaryServerInterceptor(methodScopes map[string][]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := v.authenticate(ctx, methodScopes[info.FullMethod])
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor adalah versi streaming dari UnaryServerInterceptor. Token diperiksa
+// sekali di awal setiap stream.
+func (v *Verifier) StreamServerInterceptor(methodScopes map[string][]string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := v.authenticate(ss.Context(), methodScopes[info.FullMethod])
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// contextServerStream mengganti Context() dari ServerStream dengan context yang berisi identitas service.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (v *Verifier) authenticate(ctx context.Context, requiredScopes []string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "service token required")
+	}
+	claims, err := v.Verify(ctx, values[0])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if missing := missingScopes(claims, requiredScopes); len(missing) > 0 {
+		return nil, status.Errorf(codes.PermissionDenied, "service token missing required scopes: %s", strings.Join(missing, ", "))
+	}
+	return auth.WithIdentity(ctx, newIdentity(claims)), nil
+}
+
+func missingScopes(claims *auth.PrismClaims, required []string) []string {
+	var missing []string
+	for _, scope := range required {
+		if !claims.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+func contains(items []string, item string) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
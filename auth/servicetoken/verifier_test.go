@@ -0,0 +1,240 @@
+package servicetoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testCaller   = "prism-invoice-service"
+	testAudience = "prism-user-service"
+)
+
+func newTestVerifier(t *testing.T, cfg VerifierConfig) *Verifier {
+	t.Helper()
+	if cfg.Audience == "" {
+		cfg.Audience = testAudience
+	}
+	verifier, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	return verifier
+}
+
+func mint(t *testing.T, minter *Minter, audience string, scopes ...string) string {
+	t.Helper()
+	token, _, err := minter.Mint(context.Background(), audience, scopes...)
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	return token
+}
+
+// signClaims menandatangani claims apa adanya, untuk token yang tidak bisa dibuat lewat Minter.
+func signClaims(t *testing.T, keys auth.SigningKeyProvider, claims auth.PrismClaims) string {
+	t.Helper()
+	key, err := keys.SigningKey(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestNewVerifier(t *testing.T) {
+	if _, err := NewVerifier(VerifierConfig{Keys: newTestKeySet(t, "svc-1")}); err == nil {
+		t.Error("NewVerifier without audience returned nil error")
+	}
+	if _, err := NewVerifier(VerifierConfig{Audience: testAudience}); err == nil {
+		t.Error("NewVerifier without keys returned nil error")
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	keys := newTestKeySet(t, "svc-1")
+	minter := newTestMinter(t, keys, testCaller, 0)
+	now := time.Now()
+
+	otherIssuer, err := NewMinter(MinterConfig{Service: testCaller, Keys: keys, Issuer: "someone-else"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := newTestMinter(t, keys, testCaller, time.Minute)
+	expired.now = func() time.Time { return now.Add(-time.Hour) }
+	longLived := newTestMinter(t, keys, testCaller, time.Hour)
+	untrusted := newTestMinter(t, newTestKeySet(t, "svc-1"), testCaller, 0)
+	userToken := auth.PrismClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    DefaultIssuer,
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		TokenUse: auth.TokenUseAccess,
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		cfg     VerifierConfig
+		wantErr error
+	}{
+		{"valid", mint(t, minter, testAudience, "user:read"), VerifierConfig{}, nil},
+		{"allowed service", mint(t, minter, testAudience), VerifierConfig{AllowedServices: []string{"prism-auth-service", testCaller}}, nil},
+		{"service not allowed", mint(t, minter, testAudience), VerifierConfig{AllowedServices: []string{"prism-auth-service"}}, ErrServiceNotAllowed},
+		{"token for another service", mint(t, minter, "prism-billing-service"), VerifierConfig{}, ErrInvalidToken},
+		{"wrong issuer", mint(t, otherIssuer, testAudience), VerifierConfig{}, ErrInvalidToken},
+		{"expired", mint(t, expired, testAudience), VerifierConfig{}, ErrInvalidToken},
+		{"lifetime above max ttl", mint(t, longLived, testAudience), VerifierConfig{}, ErrInvalidToken},
+		{"lifetime within custom max ttl", mint(t, longLived, testAudience), VerifierConfig{MaxTTL: time.Hour}, nil},
+		{"untrusted key", mint(t, untrusted, testAudience), VerifierConfig{}, ErrInvalidToken},
+		{"user token", signClaims(t, keys, userToken), VerifierConfig{}, ErrInvalidToken},
+		{"garbage", "not-a-jwt", VerifierConfig{}, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Keys = keys
+			verifier := newTestVerifier(t, tt.cfg)
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.Subject != testCaller {
+				t.Errorf("sub = %q, want %q", claims.Subject, testCaller)
+			}
+		})
+	}
+
+	// Verifier bisa dipakai langsung sebagai auth.ServiceTokenVerifier.
+	identity, err := newTestVerifier(t, VerifierConfig{Keys: keys}).VerifyServiceToken(context.Background(), mint(t, minter, testAudience))
+	if err != nil {
+		t.Fatalf("VerifyServiceToken: %v", err)
+	}
+	if identity.UserID != "service:"+testCaller || identity.Role != "service" || identity.AuthMethod != auth.AuthMethodServiceToken {
+		t.Errorf("identity = %+v", identity)
+	}
+}
+
+func TestVerifierMiddleware(t *testing.T) {
+	keys := newTestKeySet(t, "svc-1")
+	minter := newTestMinter(t, keys, testCaller, 0)
+	verifier := newTestVerifier(t, VerifierConfig{Keys: keys})
+
+	tests := []struct {
+		name   string
+		token  string
+		scopes []string
+		want   int
+	}{
+		{"valid without scopes", mint(t, minter, testAudience), nil, http.StatusNoContent},
+		{"has all scopes", mint(t, minter, testAudience, "user:read", "user:write"), []string{"user:read", "user:write"}, http.StatusNoContent},
+		{"missing scope", mint(t, minter, testAudience, "user:read"), []string{"user:read", "user:write"}, http.StatusForbidden},
+		{"invalid token", mint(t, minter, "prism-billing-service"), nil, http.StatusUnauthorized},
+		{"no token", "", nil, http.StatusUnauthorized},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity *auth.Identity
+			r := gin.New()
+			r.GET("/", verifier.Middleware(tt.scopes...), func(c *gin.Context) {
+				identity, _ = auth.IdentityFrom(c)
+				c.Status(http.StatusNoContent)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set(Header, tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusNoContent && (identity == nil || identity.UserID != "service:"+testCaller) {
+				t.Errorf("identity = %+v, want service:%s", identity, testCaller)
+			}
+		})
+	}
+}
+
+// testServerStream adalah grpc.ServerStream dengan context tertentu.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestVerifierInterceptors(t *testing.T) {
+	const method = "/prism.user.v1.UserService/GetPermissionsForRole"
+	keys := newTestKeySet(t, "svc-1")
+	minter := newTestMinter(t, keys, testCaller, 0)
+	verifier := newTestVerifier(t, VerifierConfig{Keys: keys})
+	methodScopes := map[string][]string{method: {"rbac:read"}}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		want   codes.Code
+	}{
+		{"valid with scope", mint(t, minter, testAudience, "rbac:read"), method, codes.OK},
+		{"missing scope", mint(t, minter, testAudience), method, codes.PermissionDenied},
+		{"method without scopes", mint(t, minter, testAudience), "/prism.user.v1.UserService/GetUser", codes.OK},
+		{"invalid token", mint(t, minter, "prism-billing-service", "rbac:read"), method, codes.Unauthenticated},
+		{"no token", "", method, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, tt.token))
+			}
+			checkIdentity := func(ctx context.Context) {
+				identity, ok := auth.IdentityFrom(ctx)
+				if !ok || identity.UserID != "service:"+testCaller {
+					t.Errorf("handler identity = %+v, want service:%s", identity, testCaller)
+				}
+			}
+
+			_, err := verifier.UnaryServerInterceptor(methodScopes)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, _ interface{}) (interface{}, error) {
+					checkIdentity(ctx)
+					return nil, nil
+				})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("unary code = %v, want %v", got, tt.want)
+			}
+
+			err = verifier.StreamServerInterceptor(methodScopes)(nil, &testServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: tt.method},
+				func(_ interface{}, ss grpc.ServerStream) error {
+					checkIdentity(ss.Context())
+					return nil
+				})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("stream code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
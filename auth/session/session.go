@@ -0,0 +1,65 @@
+// Package session mengelola sesi login yang mengelompokkan token akses dan refresh token
+// (lewat claim sid). Sesi disimpan di Redis per user dan tenant sehingga pengguna bisa melihat
+// perangkat yang sedang login, mengakhiri sesi tertentu, dan tenant bisa membatasi jumlah sesi aktif.
+//
+// Store mengimplementasikan auth.RevocationChecker; pasang dengan auth.WithSessionStore agar
+// token dari sesi yang sudah diakhiri langsung ditolak oleh JWTMiddleware, dan dengan
+// IssuerConfig.Revocation agar sesi tersebut tidak bisa di-refresh.
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSessionNotFound dikembalikan jika sesi tidak ada, sudah kedaluwarsa, atau milik user lain.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionLimitReached dikembalikan Create jika batas sesi tercapai dan policy LimitReject.
+	ErrSessionLimitReached = errors.New("maximum concurrent sessions reached")
+)
+
+// Session adalah satu login pada satu perangkat.
+type Session struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	UserID     string    `json:"user_id"`
+	DeviceID   string    `json:"device_id,omitempty"`
+	DeviceName string    `json:"device_name,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// NewSession adalah data yang dibutuhkan untuk membuat sesi saat login.
+type NewSession struct {
+	TenantID   string
+	UserID     string
+	DeviceID   string
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// LimitAction menentukan apa yang terjadi ketika user sudah mencapai MaxConcurrent sesi.
+type LimitAction int
+
+const (
+	// LimitEvictOldest mengakhiri sesi paling lama agar login baru tetap berhasil. Ini default.
+	LimitEvictOldest LimitAction = iota
+	// LimitReject menolak login baru dengan ErrSessionLimitReached.
+	LimitReject
+)
+
+// Policy adalah kebijakan sesi per tenant.
+type Policy struct {
+	// MaxConcurrent adalah jumlah maksimal sesi aktif per user. 0 berarti tidak dibatasi.
+	MaxConcurrent int
+	OnLimit       LimitAction
+}
+
+// PolicyLoader memuat Policy untuk tenant, misalnya dari pengaturan tenant di database.
+type PolicyLoader func(ctx context.Context, tenantID string) (Policy, error)
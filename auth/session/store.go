@@ -0,0 +1,370 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// touchScript memperbarui last_seen hanya jika sesi masih ada, agar Touch yang berpacu dengan
+// Terminate tidak menghidupkan kembali sesi yang sudah diakhiri.
+var touchScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+return 1
+`)
+
+// Config mengatur Store.
+type Config struct {
+	// KeyPrefix untuk semua key sesi. Default "prism:session:".
+	KeyPrefix string
+	// TTL adalah umur absolut sesi. Default auth.DefaultRefreshTokenTTL agar sesi dan
+	// refresh token pertamanya berakhir bersamaan.
+	TTL time.Duration
+	// DefaultPolicy dipakai untuk tenant yang tidak punya kebijakan khusus.
+	DefaultPolicy Policy
+	// PolicyLoader (opsional) memuat kebijakan per tenant.
+	PolicyLoader PolicyLoader
+	// TouchInterval membatasi seberapa sering LastSeenAt ditulis per sesi. Default 1 menit.
+	TouchInterval time.Duration
+}
+
+// Store menyimpan sesi di Redis. Layout key:
+//
+//	<prefix><sid>                    hash berisi field sesi, TTL = umur sesi
+//	<prefix>user:<tenantID>:<userID> sorted set sid dengan skor waktu pembuatan
+type Store struct {
+	client *redis.Client
+	cfg    Config
+	now    func() time.Time
+
+	touchMu     sync.Mutex
+	touched     map[string]struct{}
+	touchWindow time.Time
+}
+
+// NewStore membuat Store dengan nilai default untuk field Config yang kosong.
+func NewStore(client *redis.Client, cfg Config) *Store {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "prism:session:"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = auth.DefaultRefreshTokenTTL
+	}
+	if cfg.TouchInterval <= 0 {
+		cfg.TouchInterval = time.Minute
+	}
+	return &Store{
+		client:  client,
+		cfg:     cfg,
+		now:     time.Now,
+		touched: make(map[string]struct{}),
+	}
+}
+
+func (s *Store) sessionKey(sid string) string {
+	return s.cfg.KeyPrefix + sid
+}
+
+func (s *Store) userKey(tenantID, userID string) string {
+	return s.cfg.KeyPrefix + "user:" + tenantID + ":" + userID
+}
+
+func (s *Store) policy(ctx context.Context, tenantID string) (Policy, error) {
+	if s.cfg.PolicyLoader == nil {
+		return s.cfg.DefaultPolicy, nil
+	}
+	return s.cfg.PolicyLoader(ctx, tenantID)
+}
+
+// Create membuat sesi baru saat login dan menerapkan kebijakan jumlah sesi tenant.
+// ID sesi yang diakhiri karena LimitEvictOldest dikembalikan agar bisa dicatat atau diberitahukan.
+// ID sesi dipakai sebagai auth.TokenSubject.SessionID.
+func (s *Store) Create(ctx context.Context, params NewSession) (*Session, []string, error) {
+	if params.TenantID == "" || params.UserID == "" {
+		return nil, nil, fmt.Errorf("session requires tenant ID and user ID")
+	}
+	policy, err := s.policy(ctx, params.TenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load session policy for tenant '%s': %w", params.TenantID, err)
+	}
+
+	active, err := s.activeIDs(ctx, params.TenantID, params.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Batas ini tidak atomik terhadap login paralel; selisih satu sesi dapat diterima
+	// dan akan dirapikan pada login berikutnya.
+	var evicted []string
+	if policy.MaxConcurrent > 0 && len(active) >= policy.MaxConcurrent {
+		if policy.OnLimit == LimitReject {
+			return nil, nil, ErrSessionLimitReached
+		}
+		evicted = active[:len(active)-policy.MaxConcurrent+1]
+	}
+
+	now := s.now()
+	sess := &Session{
+		ID:         uuid.NewString(),
+		TenantID:   params.TenantID,
+		UserID:     params.UserID,
+		DeviceID:   params.DeviceID,
+		DeviceName: params.DeviceName,
+		IP:         params.IP,
+		UserAgent:  params.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.cfg.TTL),
+	}
+
+	userKey := s.userKey(params.TenantID, params.UserID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.sessionKey(sess.ID), map[string]interface{}{
+			"tenant_id":   sess.TenantID,
+			"user_id":     sess.UserID,
+			"device_id":   sess.DeviceID,
+			"device_name": sess.DeviceName,
+			"ip":          sess.IP,
+			"user_agent":  sess.UserAgent,
+			"created_at":  sess.CreatedAt.Unix(),
+			"last_seen":   sess.LastSeenAt.Unix(),
+			"expires_at":  sess.ExpiresAt.Unix(),
+		})
+		pipe.Expire(ctx, s.sessionKey(sess.ID), s.cfg.TTL)
+		pipe.ZAdd(ctx, userKey, redis.Z{Score: float64(now.UnixNano()), Member: sess.ID})
+		pipe.Expire(ctx, userKey, s.cfg.TTL)
+		for _, sid := range evicted {
+			pipe.Del(ctx, s.sessionKey(sid))
+			pipe.ZRem(ctx, userKey, sid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return sess, evicted, nil
+}
+
+// activeIDs mengembalikan ID sesi user yang masih hidup, terlama lebih dulu, sambil
+// membersihkan ID yang hash-nya sudah kedaluwarsa dari index.
+func (s *Store) activeIDs(ctx context.Context, tenantID, userID string) ([]string, error) {
+	userKey := s.userKey(tenantID, userID)
+	ids, err := s.client.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	pipe := s.client.Pipeline()
+	exists := make([]*redis.IntCmd, len(ids))
+	for i, sid := range ids {
+		exists[i] = pipe.Exists(ctx, s.sessionKey(sid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	active := make([]string, 0, len(ids))
+	var stale []interface{}
+	for i, sid := range ids {
+		if exists[i].Val() == 1 {
+			active = append(active, sid)
+		} else {
+			stale = append(stale, sid)
+		}
+	}
+	if len(stale) > 0 {
+		if err := s.client.ZRem(ctx, userKey, stale...).Err(); err != nil {
+			log.Printf("Peringatan: gagal membersihkan index sesi '%s': %v", userKey, err)
+		}
+	}
+	return active, nil
+}
+
+// Get mengambil satu sesi milik user. ErrSessionNotFound jika tidak ada atau milik user lain.
+func (s *Store) Get(ctx context.Context, tenantID, userID, sid string) (*Session, error) {
+	fields, err := s.client.HGetAll(ctx, s.sessionKey(sid)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	sess, ok := sessionFromHash(sid, fields)
+	if !ok || sess.TenantID != tenantID || sess.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	return sess, nil
+}
+
+// List mengembalikan semua sesi aktif user, terbaru lebih dulu.
+func (s *Store) List(ctx context.Context, tenantID, userID string) ([]Session, error) {
+	ids, err := s.activeIDs(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, sid := range ids {
+		cmds[i] = pipe.HGetAll(ctx, s.sessionKey(sid))
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read sessions: %w", err)
+		}
+	}
+
+	sessions := make([]Session, 0, len(ids))
+	for i, sid := range ids {
+		if sess, ok := sessionFromHash(sid, cmds[i].Val()); ok {
+			sessions = append(sessions, *sess)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// Terminate mengakhiri satu sesi milik user. Token akses dari sesi ini langsung ditolak
+// oleh JWTMiddleware yang memakai auth.WithSessionStore.
+func (s *Store) Terminate(ctx context.Context, tenantID, userID, sid string) error {
+	if _, err := s.Get(ctx, tenantID, userID, sid); err != nil {
+		return err
+	}
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.sessionKey(sid))
+		pipe.ZRem(ctx, s.userKey(tenantID, userID), sid)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to terminate session '%s': %w", sid, err)
+	}
+	return nil
+}
+
+// TerminateAll mengakhiri semua sesi user kecuali exceptSID (kosongkan untuk "logout dari
+// semua perangkat"). Mengembalikan jumlah sesi yang diakhiri.
+func (s *Store) TerminateAll(ctx context.Context, tenantID, userID, exceptSID string) (int, error) {
+	ids, err := s.activeIDs(ctx, tenantID, userID)
+	if err != nil {
+		return 0, err
+	}
+	userKey := s.userKey(tenantID, userID)
+	terminated := 0
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sid := range ids {
+			if sid == exceptSID {
+				continue
+			}
+			pipe.Del(ctx, s.sessionKey(sid))
+			pipe.ZRem(ctx, userKey, sid)
+			terminated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to terminate sessions for user '%s': %w", userID, err)
+	}
+	return terminated, nil
+}
+
+// Touch memperbarui LastSeenAt sesi, paling sering sekali per TouchInterval per instance.
+func (s *Store) Touch(ctx context.Context, sid string) error {
+	if !s.shouldTouch(sid) {
+		return nil
+	}
+	err := touchScript.Run(ctx, s.client, []string{s.sessionKey(sid)}, s.now().Unix()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to touch session '%s': %w", sid, err)
+	}
+	return nil
+}
+
+// shouldTouch mencatat sid yang sudah ditulis dalam jendela TouchInterval berjalan.
+// Map diganti setiap jendela baru sehingga ukurannya tetap sebatas sesi yang aktif.
+func (s *Store) shouldTouch(sid string) bool {
+	s.touchMu.Lock()
+	defer s.touchMu.Unlock()
+	now := s.now()
+	if now.Sub(s.touchWindow) >= s.cfg.TouchInterval {
+		s.touched = make(map[string]struct{})
+		s.touchWindow = now
+	}
+	if _, done := s.touched[sid]; done {
+		return false
+	}
+	s.touched[sid] = struct{}{}
+	return true
+}
+
+// IsRevoked mengimplementasikan auth.RevocationChecker. Setelah Store dipasang, setiap token
+// harus terikat sesi: token tanpa claim sid, token dengan sid yang sesinya tidak ada lagi,
+// atau sesi milik user/tenant lain dianggap dicabut. Token impersonasi terikat ke sesi
+// operatornya (claim act).
+func (s *Store) IsRevoked(ctx context.Context, claims *auth.PrismClaims) (bool, error) {
+	if claims.SessionID == "" {
+		return true, nil
+	}
+	values, err := s.client.HMGet(ctx, s.sessionKey(claims.SessionID), "tenant_id", "user_id").Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	tenantID, _ := values[0].(string)
+	userID, _ := values[1].(string)
+	ownerTenantID, ownerID := claims.TenantID, claims.Subject
+	if claims.IsImpersonated() {
+		ownerTenantID, ownerID = claims.Actor.TenantID, claims.Actor.Subject
+	}
+	return tenantID != ownerTenantID || userID != ownerID, nil
+}
+
+// Middleware memperbarui LastSeenAt untuk sesi request saat ini. Pasang setelah JWTMiddleware.
+// Kegagalan hanya dicatat; LastSeenAt bersifat informatif.
+func (s *Store) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, err := auth.ClaimsFromContext(c); err == nil && claims.SessionID != "" {
+			if err := s.Touch(c.Request.Context(), claims.SessionID); err != nil {
+				log.Printf("Peringatan: %v", err)
+			}
+		}
+		c.Next()
+	}
+}
+
+func sessionFromHash(sid string, fields map[string]string) (*Session, bool) {
+	if len(fields) == 0 || fields["user_id"] == "" {
+		return nil, false
+	}
+	return &Session{
+		ID:         sid,
+		TenantID:   fields["tenant_id"],
+		UserID:     fields["user_id"],
+		DeviceID:   fields["device_id"],
+		DeviceName: fields["device_name"],
+		IP:         fields["ip"],
+		UserAgent:  fields["user_agent"],
+		CreatedAt:  unixField(fields["created_at"]),
+		LastSeenAt: unixField(fields["last_seen"]),
+		ExpiresAt:  unixField(fields["expires_at"]),
+	}, true
+}
+
+func unixField(v string) time.Time {
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRefreshAfterTerminate(t *testing.T) {
+	ctx := context.Background()
+	keys := auth.HMACKeyProvider{Secret: []byte("session-test-secret-0123456789abcdef")}
+
+	tests := []struct {
+		name string
+		// loader mensimulasikan SubjectLoader yang tidak tahu apa-apa tentang sesi.
+		loader func(ctx context.Context, userID, tenantID string) (auth.TokenSubject, error)
+	}{
+		{"without subject loader", nil},
+		{"with subject loader", func(_ context.Context, userID, tenantID string) (auth.TokenSubject, error) {
+			return auth.TokenSubject{UserID: userID, TenantID: tenantID, Role: "staff"}, nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			store := NewStore(client, Config{})
+			issuer, err := auth.NewIssuer(keys, client, auth.IssuerConfig{
+				SubjectLoader: tt.loader,
+				Revocation:    store,
+			})
+			if err != nil {
+				t.Fatalf("NewIssuer: %v", err)
+			}
+
+			sess, _, err := store.Create(ctx, NewSession{TenantID: "tenant-a", UserID: "user-1"})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			pair, err := issuer.IssuePair(ctx, auth.TokenSubject{
+				UserID: "user-1", TenantID: "tenant-a", Role: "staff", SessionID: sess.ID,
+			})
+			if err != nil {
+				t.Fatalf("IssuePair: %v", err)
+			}
+
+			// Dua kali refresh: token hasil refresh pertama harus tetap terikat ke sesi.
+			refresh := pair.RefreshToken
+			for round := 1; round <= 2; round++ {
+				rotated, err := issuer.Refresh(ctx, refresh)
+				if err != nil {
+					t.Fatalf("Refresh round %d: %v", round, err)
+				}
+				refresh = rotated.RefreshToken
+			}
+
+			if err := store.Terminate(ctx, "tenant-a", "user-1", sess.ID); err != nil {
+				t.Fatalf("Terminate: %v", err)
+			}
+			if _, err := issuer.Refresh(ctx, refresh); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+				t.Errorf("Refresh after Terminate error = %v, want %v", err, auth.ErrInvalidRefreshToken)
+			}
+		})
+	}
+}
+
+func TestIsRevoked(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	store := NewStore(client, Config{})
+	sess, _, err := store.Create(ctx, NewSession{TenantID: "tenant-a", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	claims := func(tenantID, userID, sid string) *auth.PrismClaims {
+		c := &auth.PrismClaims{TenantID: tenantID, SessionID: sid}
+		c.Subject = userID
+		return c
+	}
+	impersonated := claims("tenant-b", "user-9", sess.ID)
+	impersonated.Actor = &auth.ActorClaim{Subject: "user-1", TenantID: "tenant-a"}
+
+	tests := []struct {
+		name   string
+		claims *auth.PrismClaims
+		want   bool
+	}{
+		{"own session", claims("tenant-a", "user-1", sess.ID), false},
+		{"missing sid", claims("tenant-a", "user-1", ""), true},
+		{"unknown sid", claims("tenant-a", "user-1", "no-such-session"), true},
+		{"other user", claims("tenant-a", "user-2", sess.ID), true},
+		{"other tenant", claims("tenant-b", "user-1", sess.ID), true},
+		{"impersonation bound to operator session", impersonated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := store.IsRevoked(ctx, tt.claims)
+			if err != nil {
+				t.Fatalf("IsRevoked: %v", err)
+			}
+			if revoked != tt.want {
+				t.Errorf("IsRevoked = %v, want %v", revoked, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey adalah kunci penandatangan beserta algoritma dan kid-nya.
+// Key berupa []byte untuk HMAC, atau *rsa.PrivateKey / *ecdsa.PrivateKey / ed25519.PrivateKey.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Key    interface{}
+}
+
+// verificationKey mengembalikan pasangan publik dari kunci penandatangan.
+func (k *SigningKey) verificationKey() (interface{}, error) {
+	switch key := k.Key.(type) {
+	case []byte:
+		return key, nil
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key.(crypto.Signer).Public(), nil
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", k.Key)
+	}
+}
+
+// SigningKeyProvider menyediakan kunci aktif untuk menandatangani token baru.
+type SigningKeyProvider interface {
+	SigningKey(ctx context.Context) (*SigningKey, error)
+}
+
+// SigningKeySet adalah sumber kunci yang bisa menandatangani sekaligus memverifikasi,
+// sehingga Issuer dan JWTMiddleware di auth service berbagi satu konfigurasi kunci.
+type SigningKeySet interface {
+	KeyProvider
+	SigningKeyProvider
+}
+
+func (p HMACKeyProvider) SigningKey(_ context.Context) (*SigningKey, error) {
+	if len(p.Secret) == 0 {
+		return nil, ErrKeyNotConfigured
+	}
+	return &SigningKey{Method: jwt.SigningMethodHS256, Key: p.Secret}, nil
+}
+
+func (p EnvHMACKeyProvider) SigningKey(ctx context.Context) (*SigningKey, error) {
+	return p.provider().SigningKey(ctx)
+}
+
+// NewSigningKeyFromPEM mem-parse kunci privat PEM (RSA, EC, atau Ed25519) dan memilih
+// algoritma yang sesuai: RS256, ES256/ES384/ES512, atau EdDSA.
+func NewSigningKeyFromPEM(kid string, pemBytes []byte) (*SigningKey, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Key: key}, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(pemBytes); err == nil {
+		var method jwt.SigningMethod
+		switch key.Curve.Params().BitSize {
+		case 256:
+			method = jwt.SigningMethodES256
+		case 384:
+			method = jwt.SigningMethodES384
+		case 521:
+			method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %s", key.Curve.Params().Name)
+		}
+		return &SigningKey{ID: kid, Method: method, Key: key}, nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes); err == nil {
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Key: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported or invalid private key PEM")
+}
+
+// KeySet menyimpan kunci penandatangan aktif beserta kunci lama yang masih dipakai
+// untuk verifikasi selama masa rotasi.
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   map[string]*SigningKey
+	active string
+}
+
+// NewKeySet membuat KeySet dengan kunci aktif dan kunci lama (opsional) yang hanya untuk verifikasi.
+func NewKeySet(active *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	s := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, k := range previous {
+		if err := s.add(k); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.add(active); err != nil {
+		return nil, err
+	}
+	s.active = active.ID
+	return s, nil
+}
+
+func (s *KeySet) add(k *SigningKey) error {
+	if k == nil || k.ID == "" {
+		return fmt.Errorf("signing key must have a key ID")
+	}
+	if _, err := k.verificationKey(); err != nil {
+		return err
+	}
+	s.keys[k.ID] = k
+	return nil
+}
+
+// Rotate menjadikan key sebagai kunci aktif. Kunci sebelumnya tetap dipakai untuk verifikasi
+// sampai dihapus dengan Remove.
+func (s *KeySet) Rotate(key *SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.add(key); err != nil {
+		return err
+	}
+	s.active = key.ID
+	return nil
+}
+
+// Remove menghapus kunci lama dari set. Kunci aktif tidak bisa dihapus.
+func (s *KeySet) Remove(kid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if kid == s.active {
+		return fmt.Errorf("cannot remove active signing key '%s'", kid)
+	}
+	delete(s.keys, kid)
+	return nil
+}
+
+func (s *KeySet) SigningKey(_ context.Context) (*SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[s.active]
+	if !ok {
+		return nil, ErrKeyNotConfigured
+	}
+	return key, nil
+}
+
+func (s *KeySet) VerificationKey(_ context.Context, token *jwt.Token) (interface{}, error) {
+	kid := keyID(token)
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownKeyID, kid)
+	}
+	if key.Method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Method.Alg())
+	}
+	return key.verificationKey()
+}
+
+// JWKS mengembalikan dokumen JWKS berisi kunci publik dari semua kunci asimetris di set.
+// Kunci HMAC tidak pernah dipublikasikan.
+func (s *KeySet) JWKS() (JWKS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKS{Keys: []JWK{}}
+	for kid, key := range s.keys {
+		if _, isHMAC := key.Key.([]byte); isHMAC {
+			continue
+		}
+		pub, err := key.verificationKey()
+		if err != nil {
+			return JWKS{}, err
+		}
+		jwk, err := NewJWK(kid, key.Method.Alg(), pub)
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
@@ -0,0 +1,130 @@
+package totp
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth/password"
+)
+
+// ErrInvalidRecoveryCode dikembalikan HashRecoveryCode untuk kode yang terlalu pendek.
+var ErrInvalidRecoveryCode = errors.New("totp: invalid recovery code")
+
+// recoveryAlphabet tanpa karakter yang mudah tertukar (0/O, 1/I/L).
+const recoveryAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+const (
+	// recoveryIDLength adalah jumlah karakter awal kode yang menjadi lookup id. Id disimpan
+	// terbuka di depan hash dan unik dalam satu set kode, sehingga VerifyRecoveryCode hanya
+	// menjalankan satu verifikasi argon2id per percobaan.
+	recoveryIDLength = 2
+	// recoverySecretLength adalah jumlah karakter rahasia setelah id (~50 bit).
+	recoverySecretLength = 10
+)
+
+// recoveryHasher meng-hash recovery code dengan argon2id bersalt acak per kode. Parameternya
+// adalah minimum OWASP (m=19 MiB, t=2, p=1), bukan default password: kode sudah ber-entropi
+// ~50 bit di luar lookup id.
+var recoveryHasher = password.NewHasher(password.Config{
+	Argon2id: password.Argon2idParams{Memory: 19 * 1024, Iterations: 2, Parallelism: 1},
+})
+
+// dummyRecoveryHash diverifikasi ketika lookup id tidak cocok dengan hash mana pun, agar waktu
+// respons tidak membedakan id yang ada dan tidak ada.
+var dummyRecoveryHash = sync.OnceValue(func() string {
+	hash, _ := recoveryHasher.Hash(strings.Repeat("2", recoveryIDLength+recoverySecretLength))
+	return hash
+})
+
+// GenerateRecoveryCodes membuat n recovery code berformat "XXXXXX-XXXXXX" beserta hash-nya.
+// Dua karakter pertama adalah lookup id yang unik dalam set ini. Kode ditampilkan sekali ke
+// pengguna; hanya hashes yang disimpan.
+func GenerateRecoveryCodes(n int) (codes []string, hashes []string, err error) {
+	maxCodes := 1
+	for i := 0; i < recoveryIDLength; i++ {
+		maxCodes *= len(recoveryAlphabet)
+	}
+	if n > maxCodes {
+		return nil, nil, fmt.Errorf("totp: at most %d recovery codes per set, got %d", maxCodes, n)
+	}
+
+	codes = make([]string, 0, n)
+	hashes = make([]string, 0, n)
+	usedIDs := make(map[string]struct{}, n)
+	for len(codes) < n {
+		raw, err := randomRecoveryChars(recoveryIDLength + recoverySecretLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		id := raw[:recoveryIDLength]
+		if _, used := usedIDs[id]; used {
+			continue
+		}
+		usedIDs[id] = struct{}{}
+
+		half := len(raw) / 2
+		code := raw[:half] + "-" + raw[half:]
+		hash, err := HashRecoveryCode(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
+
+func randomRecoveryChars(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		// Bias modulo kecil di sini tidak berarti untuk kode sekali pakai 50-bit.
+		buf[i] = recoveryAlphabet[int(b)%len(recoveryAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// HashRecoveryCode menghasilkan "<id>:<hash argon2id PHC>" dari recovery code yang sudah
+// dinormalisasi, sehingga huruf kecil dan tanda hubung tidak memengaruhi hasil. Salt acak
+// membuat kode yang sama menghasilkan hash berbeda untuk setiap pengguna.
+func HashRecoveryCode(code string) (string, error) {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) <= recoveryIDLength {
+		return "", ErrInvalidRecoveryCode
+	}
+	hash, err := recoveryHasher.Hash(normalized)
+	if err != nil {
+		return "", err
+	}
+	return normalized[:recoveryIDLength] + ":" + hash, nil
+}
+
+// VerifyRecoveryCode mencari code di antara hashes yang tersimpan. Jika cocok, indeks hash
+// dikembalikan dan caller wajib menghapusnya agar kode tidak dapat dipakai lagi.
+func VerifyRecoveryCode(code string, hashes []string) (int, bool) {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) <= recoveryIDLength {
+		return -1, false
+	}
+	prefix := normalized[:recoveryIDLength] + ":"
+	for i, h := range hashes {
+		if hash, ok := strings.CutPrefix(h, prefix); ok {
+			matched, _, err := recoveryHasher.Verify(normalized, hash)
+			if err == nil && matched {
+				return i, true
+			}
+			return -1, false
+		}
+	}
+	_, _, _ = recoveryHasher.Verify(normalized, dummyRecoveryHash())
+	return -1, false
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
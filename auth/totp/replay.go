@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ReplayStore mencatat step terakhir yang diterima per subject agar satu kode tidak bisa
+// diterima dua kali, dan kode lama yang masih di dalam jendela skew tidak bisa dipakai setelah
+// kode yang lebih baru diterima.
+type ReplayStore interface {
+	// MarkUsed mencatat step sebagai step terakhir milik subject selama ttl. Mengembalikan false
+	// jika step lebih kecil atau sama dengan step terakhir yang sudah tercatat.
+	MarkUsed(ctx context.Context, subject string, step uint64, ttl time.Duration) (bool, error)
+}
+
+// MemoryReplayStore adalah ReplayStore in-process untuk pengujian dan deployment satu instance.
+type MemoryReplayStore struct {
+	mu   sync.Mutex
+	last map[string]usedStep
+}
+
+type usedStep struct {
+	step      uint64
+	expiresAt time.Time
+}
+
+// NewMemoryReplayStore membuat MemoryReplayStore kosong.
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{last: make(map[string]usedStep)}
+}
+
+func (s *MemoryReplayStore) MarkUsed(_ context.Context, subject string, step uint64, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Entri kedaluwarsa dibersihkan di sini; jumlahnya sebatas subject yang login dalam beberapa periode terakhir.
+	for k, used := range s.last {
+		if !used.expiresAt.After(now) {
+			delete(s.last, k)
+		}
+	}
+	if used, exists := s.last[subject]; exists && step <= used.step {
+		return false, nil
+	}
+	s.last[subject] = usedStep{step: step, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// markUsedScript menyimpan step hanya jika lebih besar dari step terakhir, secara atomik.
+// Return 1 = diterima, 0 = replay atau step lama.
+var markUsedScript = redis.NewScript(`
+local last = redis.call('GET', KEYS[1])
+if last and tonumber(last) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// RedisReplayStore adalah ReplayStore bersama untuk semua instance service.
+type RedisReplayStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisReplayStore membuat RedisReplayStore. keyPrefix default "prism:totp:used:".
+func NewRedisReplayStore(client *redis.Client, keyPrefix string) *RedisReplayStore {
+	if keyPrefix == "" {
+		keyPrefix = "prism:totp:used:"
+	}
+	return &RedisReplayStore{client: client, keyPrefix: keyPrefix}
+}
+
+func (s *RedisReplayStore) MarkUsed(ctx context.Context, subject string, step uint64, ttl time.Duration) (bool, error) {
+	result, err := markUsedScript.Run(ctx, s.client, []string{s.keyPrefix + subject},
+		strconv.FormatUint(step, 10), ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
@@ -0,0 +1,235 @@
+// Package totp menyediakan implementasi bersama RFC 6238 (TOTP) untuk 2FA pengguna:
+// pembuatan secret dan URI provisioning, validasi kode dengan toleransi skew,
+// pencegahan replay, dan recovery code yang disimpan dalam bentuk hash.
+//
+// Secret disimpan di model.User.TOTPSecret dalam bentuk base32 seperti yang dihasilkan GenerateSecret.
+package totp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSecret dikembalikan jika secret bukan base32 yang valid.
+	ErrInvalidSecret = errors.New("totp: invalid secret")
+	// ErrInvalidConfig dikembalikan untuk Digits atau Period di luar rentang yang didukung.
+	ErrInvalidConfig = errors.New("totp: invalid config")
+)
+
+// Algorithm adalah fungsi HMAC yang dipakai untuk menghitung kode.
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// Config mengatur parameter TOTP. Nilai default (SHA1, 6 digit, 30 detik) adalah yang
+// didukung oleh semua aplikasi authenticator populer; ubah hanya jika klien mendukungnya.
+type Config struct {
+	// Issuer ditampilkan di aplikasi authenticator, misalnya "Prism".
+	Issuer    string
+	Algorithm Algorithm
+	// Digits antara 6 dan 8 (RFC 4226 §5.3).
+	Digits int
+	// Period minimal 1 detik dan harus kelipatan detik.
+	Period time.Duration
+	// Skew adalah jumlah periode sebelum/sesudah periode saat ini yang masih diterima; DefaultConfig memakai 1.
+	Skew int
+}
+
+const secretSize = 20
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func (c Config) withDefaults() Config {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmSHA1
+	}
+	if c.Digits <= 0 {
+		c.Digits = 6
+	}
+	if c.Period <= 0 {
+		c.Period = 30 * time.Second
+	}
+	if c.Skew < 0 {
+		c.Skew = 0
+	}
+	return c
+}
+
+// validate memeriksa Config yang sudah melalui withDefaults. Period di bawah satu detik
+// menyebabkan pembagian dengan nol di step, dan Digits di atas 9 melimpahkan modulus uint32.
+func (c Config) validate() error {
+	if c.Digits < 6 || c.Digits > 8 {
+		return fmt.Errorf("%w: digits must be between 6 and 8, got %d", ErrInvalidConfig, c.Digits)
+	}
+	if c.Period < time.Second || c.Period%time.Second != 0 {
+		return fmt.Errorf("%w: period must be a whole number of seconds, got %s", ErrInvalidConfig, c.Period)
+	}
+	switch c.Algorithm {
+	case AlgorithmSHA1, AlgorithmSHA256, AlgorithmSHA512:
+	default:
+		return fmt.Errorf("%w: unsupported algorithm '%s'", ErrInvalidConfig, c.Algorithm)
+	}
+	return nil
+}
+
+// DefaultConfig mengembalikan konfigurasi standar dengan Skew 1 periode.
+func DefaultConfig(issuer string) Config {
+	return Config{Issuer: issuer, Skew: 1}.withDefaults()
+}
+
+// GenerateSecret membuat secret acak 160-bit dalam base32 tanpa padding.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(buf), nil
+}
+
+// ProvisioningURI membangun URI otpauth:// untuk ditampilkan sebagai QR code saat enrolment.
+func (c Config) ProvisioningURI(accountName, secret string) string {
+	c = c.withDefaults()
+
+	label := accountName
+	if c.Issuer != "" {
+		label = c.Issuer + ":" + accountName
+	}
+	params := url.Values{}
+	params.Set("secret", secret)
+	if c.Issuer != "" {
+		params.Set("issuer", c.Issuer)
+	}
+	params.Set("algorithm", string(c.Algorithm))
+	params.Set("digits", strconv.Itoa(c.Digits))
+	params.Set("period", strconv.Itoa(int(c.Period/time.Second)))
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: params.Encode()}
+	return u.String()
+}
+
+// GenerateCode menghitung kode untuk waktu t. Terutama berguna untuk pengujian dan tooling.
+func (c Config) GenerateCode(secret string, t time.Time) (string, error) {
+	c = c.withDefaults()
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return c.code(key, c.step(t)), nil
+}
+
+func (c Config) step(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(c.Period/time.Second)
+}
+
+// code mengimplementasikan HOTP (RFC 4226) untuk counter tertentu.
+func (c Config) code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(c.Algorithm.hash(), key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < c.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", c.Digits, value%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := b32.DecodeString(normalized)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// Validator memvalidasi kode TOTP dan, jika ReplayStore dipasang, menolak kode yang sudah pernah dipakai.
+type Validator struct {
+	cfg    Config
+	replay ReplayStore
+	now    func() time.Time
+}
+
+// NewValidator membuat Validator dan menolak Config yang tidak valid. replay boleh nil, tetapi
+// tanpa ReplayStore kode yang sama dapat dipakai ulang selama masih berada di dalam jendela skew.
+func NewValidator(cfg Config, replay ReplayStore) (*Validator, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Validator{cfg: cfg, replay: replay, now: time.Now}, nil
+}
+
+// Validate memeriksa kode untuk subject (biasanya "<tenantID>:<userID>") dengan secret miliknya.
+// Mengembalikan false tanpa error untuk kode yang salah atau sudah dipakai; error hanya untuk
+// secret yang rusak atau kegagalan ReplayStore.
+func (v *Validator) Validate(ctx context.Context, subject, secret, code string) (bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return false, err
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != v.cfg.Digits {
+		return false, nil
+	}
+
+	current := v.cfg.step(v.now())
+	for offset := -v.cfg.Skew; offset <= v.cfg.Skew; offset++ {
+		step := current + uint64(offset)
+		if offset < 0 && current < uint64(-offset) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(v.cfg.code(key, step)), []byte(code)) != 1 {
+			continue
+		}
+		if v.replay == nil {
+			return true, nil
+		}
+		// Kode tetap berlaku selama jendela skew, jadi step terakhir harus diingat setidaknya selama itu.
+		ttl := time.Duration(2*v.cfg.Skew+1) * v.cfg.Period
+		fresh, err := v.replay.MarkUsed(ctx, subject, step, ttl)
+		if err != nil {
+			return false, fmt.Errorf("totp: replay check failed: %w", err)
+		}
+		return fresh, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,204 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Vektor uji dari RFC 6238 Appendix B (8 digit, periode 30 detik).
+func TestGenerateCodeRFC6238(t *testing.T) {
+	secrets := map[Algorithm]string{
+		AlgorithmSHA1:   b32.EncodeToString([]byte("12345678901234567890")),
+		AlgorithmSHA256: b32.EncodeToString([]byte("12345678901234567890123456789012")),
+		AlgorithmSHA512: b32.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234")),
+	}
+	tests := []struct {
+		unix int64
+		alg  Algorithm
+		want string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{1111111111, AlgorithmSHA1, "14050471"},
+		{1111111111, AlgorithmSHA256, "67062674"},
+		{1111111111, AlgorithmSHA512, "99943326"},
+		{1234567890, AlgorithmSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, "38618901"},
+		{20000000000, AlgorithmSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.alg)+"/"+time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			cfg := Config{Algorithm: tt.alg, Digits: 8, Period: 30 * time.Second}
+			got, err := cfg.GenerateCode(secrets[tt.alg], time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("GenerateCode: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateCode = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"defaults", Config{}, false},
+		{"eight digits", Config{Digits: 8}, false},
+		{"too few digits", Config{Digits: 5}, true},
+		{"too many digits", Config{Digits: 10}, true},
+		{"sub-second period", Config{Period: 500 * time.Millisecond}, true},
+		{"fractional period", Config{Period: 1500 * time.Millisecond}, true},
+		{"unknown algorithm", Config{Algorithm: "MD5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewValidator(tt.cfg, nil)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("NewValidator error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error %v is not ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestValidatorRejectsReplay(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	stores := map[string]ReplayStore{
+		"memory": NewMemoryReplayStore(),
+		"redis":  NewRedisReplayStore(client, ""),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := DefaultConfig("Prism")
+			secret := b32.EncodeToString([]byte("12345678901234567890"))
+			v, err := NewValidator(cfg, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Unix(1700000010, 0)
+			v.now = func() time.Time { return now }
+
+			current, _ := cfg.GenerateCode(secret, now)
+			previous, _ := cfg.GenerateCode(secret, now.Add(-cfg.Period))
+
+			steps := []struct {
+				name string
+				code string
+				want bool
+			}{
+				{"wrong code", "000000", false},
+				{"current code", current, true},
+				{"same code again", current, false},
+				// Kode periode sebelumnya masih di dalam skew, tetapi lebih lama dari kode yang sudah diterima.
+				{"older code after newer", previous, false},
+			}
+			for _, step := range steps {
+				ok, err := v.Validate(ctx, "tenant-a:user-1", secret, step.code)
+				if err != nil {
+					t.Fatalf("%s: Validate: %v", step.name, err)
+				}
+				if ok != step.want {
+					t.Errorf("%s: Validate = %v, want %v", step.name, ok, step.want)
+				}
+			}
+
+			// Subject lain tidak terpengaruh oleh step milik subject pertama.
+			ok, err := v.Validate(ctx, "tenant-a:user-2", secret, current)
+			if err != nil || !ok {
+				t.Errorf("other subject: Validate = %v, %v; want true", ok, err)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, code := range codes {
+		if len(code) != 13 || code[6] != '-' {
+			t.Errorf("code %q is not formatted as XXXXXX-XXXXXX", code)
+		}
+		if !strings.HasPrefix(hashes[i], code[:recoveryIDLength]+":$argon2id$") {
+			t.Errorf("hash %q does not start with the lookup id of %q", hashes[i], code)
+		}
+	}
+	// Kode dengan lookup id yang sama tetapi rahasia berbeda.
+	wrongSecret := codes[0][:recoveryIDLength] + "2222-222222"
+	if wrongSecret == codes[0] {
+		wrongSecret = codes[0][:recoveryIDLength] + "3333-333333"
+	}
+	// Hash SHA-256 hex tanpa salt tidak diterima.
+	unsalted := "f57cbf1e3e471b25db4b8ad0844df41b2dae9c7d1944944d128e49294d412f66"
+
+	tests := []struct {
+		name      string
+		code      string
+		hashes    []string
+		wantIndex int
+		wantOK    bool
+	}{
+		{"first code", codes[0], hashes, 0, true},
+		{"second code", codes[1], hashes, 1, true},
+		{"lowercase without dash", strings.ToLower(strings.ReplaceAll(codes[1], "-", "")), hashes, 1, true},
+		{"known id wrong secret", wrongSecret, hashes, -1, false},
+		{"unknown code", "ZZZZZZ-ZZZZZZ", hashes, -1, false},
+		{"too short", codes[0][:recoveryIDLength], hashes, -1, false},
+		{"no hashes", codes[0], nil, -1, false},
+		{"unsalted sha256", "ABCDE-FGHJK", []string{unsalted}, -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, ok := VerifyRecoveryCode(tt.code, tt.hashes)
+			if index != tt.wantIndex || ok != tt.wantOK {
+				t.Errorf("VerifyRecoveryCode = (%d, %v), want (%d, %v)", index, ok, tt.wantIndex, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateRecoveryCodesUniqueIDs(t *testing.T) {
+	codes, _, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		id := code[:recoveryIDLength]
+		if seen[id] {
+			t.Errorf("lookup id %q used twice in %q", id, codes)
+		}
+		seen[id] = true
+	}
+
+	if _, _, err := GenerateRecoveryCodes(len(recoveryAlphabet)*len(recoveryAlphabet) + 1); err == nil {
+		t.Error("GenerateRecoveryCodes accepted more codes than there are lookup ids")
+	}
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// resetTimeout membatasi waktu reset session variable saat koneksi dikembalikan ke pool.
+const resetTimeout = 5 * time.Second
+
+// Pastikan TenantDB bisa dipakai di mana pun DBTX diterima, misalnya repository yang
+// menerima pool maupun transaksi.
+var _ DBTX = (*TenantDB)(nil)
+
+// NewTenantDBWithConfig membuat pool dari cfg dengan hook ConfigurePool terpasang. Karena
+// hook dijamin ada, reset session variable dilakukan di background oleh AfterRelease dan
+// Exec/Query/QueryRow tidak menambah satu round trip untuk reset.
+//
+//	cfg, _ := pgxpool.ParseConfig(dsn)
+//	tenantDB, err := db.NewTenantDBWithConfig(ctx, cfg)
+func NewTenantDBWithConfig(ctx context.Context, cfg *pgxpool.Config, opts ...Option) (*TenantDB, error) {
+	ConfigurePool(cfg)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	d := NewTenantDB(pool, opts...)
+	d.resetByHook = true
+	return d, nil
+}
+
+// ConfigurePool memasang hook AfterRelease yang mengosongkan semua session variable RLS
+// (app.tenant_id, app.user_id, dan seterusnya) sebelum koneksi kembali ke pool. Koneksi
+// yang gagal di-reset dibuang. Hook AfterRelease yang sudah ada tetap dipanggil setelahnya.
+// Berguna juga untuk pool yang dipakai lewat GetPool; TenantDB dari NewTenantDB tetap
+// melakukan reset sendiri karena tidak bisa memastikan hook ini terpasang.
+func ConfigurePool(cfg *pgxpool.Config) {
+	previous := cfg.AfterRelease
+	cfg.AfterRelease = func(conn *pgx.Conn) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), resetTimeout)
+		defer cancel()
+		if err := applySettings(ctx, conn, withBlanks(nil), false); err != nil {
+			log.Printf("Peringatan: gagal mereset konteks tenant, koneksi dibuang: %v", err)
+			return false
+		}
+		if previous != nil {
+			return previous(conn)
+		}
+		return true
+	}
+}
+
+// acquire mengambil koneksi dan mengatur session variable RLS dari ctx di level sesi.
+// release wajib dipanggil tepat sekali setelah statement selesai.
+func (d *TenantDB) acquire(ctx context.Context) (*pgxpool.Conn, func(), error) {
+	settings, err := sessionSettings(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := applySettings(ctx, conn, withBlanks(settings), false); err != nil {
+		// Status sesi tidak pasti; jangan kembalikan koneksi ini ke pool.
+		_ = conn.Hijack().Close(context.WithoutCancel(ctx))
+		return nil, nil, fmt.Errorf("gagal mengatur RLS context: %w", err)
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { d.release(ctx, conn) })
+	}
+	return conn, release, nil
+}
+
+// release mengosongkan session variable (kecuali pool dari NewTenantDBWithConfig, yang
+// melakukannya lewat hook) lalu mengembalikan koneksi ke pool.
+func (d *TenantDB) release(ctx context.Context, conn *pgxpool.Conn) {
+	if d.resetByHook {
+		conn.Release()
+		return
+	}
+	resetCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resetTimeout)
+	defer cancel()
+	if err := applySettings(resetCtx, conn, withBlanks(nil), false); err != nil {
+		log.Printf("Peringatan: gagal mereset konteks tenant, koneksi dibuang: %v", err)
+		_ = conn.Hijack().Close(resetCtx)
+		return
+	}
+	conn.Release()
+}
+
+// Exec menjalankan satu statement di luar transaksi dengan konteks tenant dari ctx.
+// Untuk beberapa statement yang harus atomik, gunakan WithTx.
+func (d *TenantDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer release()
+	return conn.Exec(ctx, sql, args...)
+}
+
+// Query menjalankan satu query di luar transaksi dengan konteks tenant dari ctx. Koneksi
+// dikembalikan ke pool saat rows ditutup atau selesai diiterasi.
+func (d *TenantDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &tenantRows{Rows: rows, release: release}, nil
+}
+
+// QueryRow seperti Query untuk satu baris; koneksi dikembalikan ke pool setelah Scan.
+func (d *TenantDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := d.Query(ctx, sql, args...)
+	return &tenantRow{rows: rows, err: err}
+}
+
+// tenantRows mengembalikan koneksi ke pool saat rows ditutup.
+type tenantRows struct {
+	pgx.Rows
+	release func()
+}
+
+func (r *tenantRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+func (r *tenantRows) Close() {
+	r.Rows.Close()
+	r.release()
+}
+
+// tenantRow mengikuti semantik pgx.Row: error query dan pgx.ErrNoRows dikembalikan dari Scan.
+type tenantRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *tenantRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeRows adalah pgx.Rows dengan sejumlah baris berisi int, dan error opsional dari Scan atau Err.
+type fakeRows struct {
+	pgx.Rows
+	rows    []int
+	next    int
+	scanErr error
+	err     error
+	closed  int
+}
+
+func (r *fakeRows) Next() bool {
+	if r.closed > 0 || r.next >= len(r.rows) {
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	if r.scanErr != nil {
+		return r.scanErr
+	}
+	*dest[0].(*int) = r.rows[r.next-1]
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	return r.err
+}
+
+func (r *fakeRows) Close() {
+	r.closed++
+}
+
+// newTestTenantRows membungkus rows seperti TenantDB.Query, dengan release yang sekali jalan
+// seperti dari acquire, dan penghitung pemanggilan release.
+func newTestTenantRows(rows *fakeRows) (*tenantRows, *int) {
+	released := 0
+	done := false
+	return &tenantRows{Rows: rows, release: func() {
+		if !done {
+			done = true
+			released++
+		}
+	}}, &released
+}
+
+func TestTenantRowsRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		use  func(r *tenantRows)
+	}{
+		{"iterated to the end", func(r *tenantRows) {
+			for r.Next() {
+			}
+		}},
+		{"closed early", func(r *tenantRows) {
+			r.Next()
+			r.Close()
+		}},
+		// Pola umum: defer rows.Close() setelah loop selesai.
+		{"iterated then closed", func(r *tenantRows) {
+			for r.Next() {
+			}
+			r.Close()
+		}},
+		{"collected with pgx helper", func(r *tenantRows) {
+			_, _ = pgx.CollectRows[int](r, pgx.RowTo[int])
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := &fakeRows{rows: []int{1, 2}}
+			r, released := newTestTenantRows(rows)
+			tt.use(r)
+			if *released != 1 {
+				t.Errorf("release called %d times, want 1", *released)
+			}
+			if rows.closed == 0 {
+				t.Error("underlying rows not closed")
+			}
+		})
+	}
+
+	rows := &fakeRows{rows: []int{1, 2}}
+	r, released := newTestTenantRows(rows)
+	if !r.Next() || *released != 0 {
+		t.Fatalf("connection released while rows remain (released %d)", *released)
+	}
+}
+
+func TestTenantRowScan(t *testing.T) {
+	queryErr := errors.New("relation does not exist")
+	scanErr := errors.New("cannot scan text into int")
+	iterErr := errors.New("conn reset")
+
+	tests := []struct {
+		name    string
+		rows    *fakeRows
+		err     error
+		want    int
+		wantErr error
+	}{
+		{"one row", &fakeRows{rows: []int{7}}, nil, 7, nil},
+		{"first of many rows", &fakeRows{rows: []int{7, 8}}, nil, 7, nil},
+		{"no rows", &fakeRows{}, nil, 0, pgx.ErrNoRows},
+		{"iteration error without rows", &fakeRows{err: iterErr}, nil, 0, iterErr},
+		{"scan error", &fakeRows{rows: []int{7}, scanErr: scanErr}, nil, 0, scanErr},
+		{"error after scan", &fakeRows{rows: []int{7}, err: iterErr}, nil, 7, iterErr},
+		{"query error", nil, queryErr, 0, queryErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var row *tenantRow
+			released := new(int)
+			if tt.rows != nil {
+				var r *tenantRows
+				r, released = newTestTenantRows(tt.rows)
+				row = &tenantRow{rows: r}
+			} else {
+				// Query gagal: TenantDB.Query sudah melepas koneksi sebelum mengembalikan error.
+				row = &tenantRow{err: tt.err}
+			}
+
+			var got int
+			err := row.Scan(&got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Scan error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Scan value = %d, want %d", got, tt.want)
+			}
+			if tt.rows != nil {
+				if *released != 1 {
+					t.Errorf("release called %d times, want 1", *released)
+				}
+				if tt.rows.closed == 0 {
+					t.Error("underlying rows not closed")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/ginutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Session variable yang diisi TenantDB dan bisa dibaca policy RLS.
+//
+// Nilai yang tidak diketahui selalu berupa string kosong, bukan NULL: setelah sebuah variable
+// pernah di-set di koneksi (termasuk oleh reset TenantDB), Postgres mengembalikan string kosong
+// untuknya. Policy harus menormalkannya ke NULL sebelum cast dan tidak memakai IS NULL langsung:
+//
+//	NULLIF(current_setting('app.user_id', true), '')::uuid
+//
+// app.tenant_id selalu UUID. app.user_id hanya diisi untuk identitas pengguna (JWT dan API key
+// milik pengguna), sehingga juga selalu UUID atau kosong; identitas service dan mTLS
+// ("service:<nama>") tidak mengisinya.
+const (
+	SettingTenantID  = "app.tenant_id"
+	SettingUserID    = "app.user_id"
+	SettingRole      = "app.role"
+	SettingRequestID = "app.request_id"
+	// SettingActorID berisi operator sebenarnya jika request memakai token impersonasi.
+	SettingActorID = "app.actor_id"
+)
+
+// settingNames adalah semua session variable yang dikelola TenantDB.
+var settingNames = []string{SettingTenantID, SettingUserID, SettingRole, SettingRequestID, SettingActorID}
+
+type setting struct {
+	name  string
+	value string
+}
+
+// sessionSettings mengumpulkan session variable dari identitas dan request ID di ctx.
+// Tenant wajib ada dan harus UUID; variable lain hanya diisi jika tersedia dan dikosongkan
+// oleh withBlanks jika tidak diketahui.
+func sessionSettings(ctx context.Context) ([]setting, error) {
+	tenantID, err := auth.GetTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menentukan tenant untuk query tenant-aware: %w", err)
+	}
+	// Nilai dikirim sebagai bind parameter, tetapi tenant tetap divalidasi agar tenant
+	// yang rusak ditolak di sini, bukan saat policy RLS melakukan cast ke uuid.
+	if _, err := uuid.Parse(tenantID); err != nil {
+		return nil, fmt.Errorf("invalid tenant ID format provided in context: %w", err)
+	}
+
+	settings := []setting{{SettingTenantID, tenantID}}
+	if identity, ok := auth.IdentityFrom(ctx); ok {
+		if isUserIdentity(identity) && identity.UserID != "" {
+			settings = append(settings, setting{SettingUserID, identity.UserID})
+		}
+		if identity.Role != "" {
+			settings = append(settings, setting{SettingRole, identity.Role})
+		}
+		if identity.Actor != nil && identity.Actor.Subject != "" {
+			settings = append(settings, setting{SettingActorID, identity.Actor.Subject})
+		}
+	}
+	if requestID, ok := ginutil.RequestIDFromContext(ctx); ok {
+		settings = append(settings, setting{SettingRequestID, requestID})
+	}
+	return settings, nil
+}
+
+// isUserIdentity memeriksa apakah identity mewakili pengguna, bukan service atau sertifikat mTLS
+// yang UserID-nya bukan ID pengguna.
+func isUserIdentity(identity *auth.Identity) bool {
+	switch identity.AuthMethod {
+	case auth.AuthMethodServiceToken, auth.AuthMethodMTLS:
+		return false
+	}
+	return identity.Claims == nil || identity.Claims.TokenUse != auth.TokenUseService
+}
+
+// withBlanks melengkapi settings dengan string kosong untuk variable yang tidak diketahui.
+// Dipakai untuk setting level sesi, agar nilai dari pemakai koneksi sebelumnya tidak pernah terbawa.
+func withBlanks(settings []setting) []setting {
+	all := make([]setting, 0, len(settingNames))
+	for _, name := range settingNames {
+		s := setting{name: name}
+		for _, given := range settings {
+			if given.name == name {
+				s.value = given.value
+			}
+		}
+		all = append(all, s)
+	}
+	return all
+}
+
+// execer adalah bagian DBTX yang dibutuhkan applySettings; dipenuhi pgx.Tx dan *pgxpool.Conn.
+type execer interface {
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+}
+
+// applySettings mengisi semua settings dalam satu round trip dengan
+// SELECT set_config($1, $2, local), set_config($3, $4, local), ...
+// local true membatasi nilai ke transaksi yang sedang berjalan (setara SET LOCAL).
+func applySettings(ctx context.Context, conn execer, settings []setting, local bool) error {
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	args := make([]interface{}, 0, len(settings)*2+1)
+	args = append(args, local)
+	for i, s := range settings {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "set_config($%d, $%d, $1)", len(args)+1, len(args)+2)
+		args = append(args, s.name, s.value)
+	}
+	_, err := conn.Exec(ctx, query.String(), args...)
+	return err
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/ginutil"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	testTenantID = "6f1c2a4e-1b2d-4c3e-8f90-0a1b2c3d4e5f"
+	testUserID   = "0d9e8f7a-6b5c-4d3e-a2f1-0e9d8c7b6a59"
+)
+
+func TestWithBlanks(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []setting
+		want     []setting
+	}{
+		{"reset", nil, []setting{
+			{SettingTenantID, ""}, {SettingUserID, ""}, {SettingRole, ""}, {SettingRequestID, ""}, {SettingActorID, ""},
+		}},
+		{"tenant only", []setting{{SettingTenantID, testTenantID}}, []setting{
+			{SettingTenantID, testTenantID}, {SettingUserID, ""}, {SettingRole, ""}, {SettingRequestID, ""}, {SettingActorID, ""},
+		}},
+		// Urutan selalu mengikuti settingNames, apa pun urutan masukan.
+		{"all given out of order", []setting{
+			{SettingActorID, "operator-1"}, {SettingRequestID, "req-1"}, {SettingRole, "staff"}, {SettingUserID, testUserID}, {SettingTenantID, testTenantID},
+		}, []setting{
+			{SettingTenantID, testTenantID}, {SettingUserID, testUserID}, {SettingRole, "staff"}, {SettingRequestID, "req-1"}, {SettingActorID, "operator-1"},
+		}},
+		{"unknown setting ignored", []setting{{"app.other", "x"}}, []setting{
+			{SettingTenantID, ""}, {SettingUserID, ""}, {SettingRole, ""}, {SettingRequestID, ""}, {SettingActorID, ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withBlanks(tt.settings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withBlanks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionSettings(t *testing.T) {
+	withIdentity := func(identity *auth.Identity) context.Context {
+		return auth.WithIdentity(context.Background(), identity)
+	}
+	serviceClaims := &auth.PrismClaims{TokenUse: auth.TokenUseService}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    []setting
+		wantErr bool
+	}{
+		{"no identity", context.Background(), nil, true},
+		{"empty tenant", withIdentity(&auth.Identity{UserID: testUserID}), nil, true},
+		{"tenant is not a uuid", withIdentity(&auth.Identity{TenantID: "tenant-a'; DROP TABLE users; --"}), nil, true},
+		{"jwt user", withIdentity(&auth.Identity{TenantID: testTenantID, UserID: testUserID, Role: "staff", AuthMethod: auth.AuthMethodJWT}),
+			[]setting{{SettingTenantID, testTenantID}, {SettingUserID, testUserID}, {SettingRole, "staff"}}, false},
+		{"api key user", withIdentity(&auth.Identity{TenantID: testTenantID, UserID: testUserID, AuthMethod: auth.AuthMethodAPIKey}),
+			[]setting{{SettingTenantID, testTenantID}, {SettingUserID, testUserID}}, false},
+		// UserID "service:<nama>" bukan UUID pengguna dan tidak boleh masuk ke app.user_id.
+		{"service token", withIdentity(&auth.Identity{TenantID: testTenantID, UserID: "service:billing", Role: "service", AuthMethod: auth.AuthMethodServiceToken}),
+			[]setting{{SettingTenantID, testTenantID}, {SettingRole, "service"}}, false},
+		{"mtls", withIdentity(&auth.Identity{TenantID: testTenantID, UserID: "service:billing", AuthMethod: auth.AuthMethodMTLS}),
+			[]setting{{SettingTenantID, testTenantID}}, false},
+		{"service claims via jwt", withIdentity(&auth.Identity{TenantID: testTenantID, UserID: "service:billing", AuthMethod: auth.AuthMethodJWT, Claims: serviceClaims}),
+			[]setting{{SettingTenantID, testTenantID}}, false},
+		{"impersonation", withIdentity(&auth.Identity{TenantID: testTenantID, UserID: testUserID, Actor: &auth.ActorClaim{Subject: "operator-1"}}),
+			[]setting{{SettingTenantID, testTenantID}, {SettingUserID, testUserID}, {SettingActorID, "operator-1"}}, false},
+		{"request id", ginutil.WithRequestID(withIdentity(&auth.Identity{TenantID: testTenantID}), "req-1"),
+			[]setting{{SettingTenantID, testTenantID}, {SettingRequestID, "req-1"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sessionSettings(tt.ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sessionSettings error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sessionSettings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUserIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *auth.Identity
+		want     bool
+	}{
+		{"jwt", &auth.Identity{AuthMethod: auth.AuthMethodJWT, Claims: &auth.PrismClaims{TokenUse: auth.TokenUseAccess}}, true},
+		{"api key", &auth.Identity{AuthMethod: auth.AuthMethodAPIKey}, true},
+		{"manual identity", &auth.Identity{}, true},
+		{"service token", &auth.Identity{AuthMethod: auth.AuthMethodServiceToken}, false},
+		{"mtls", &auth.Identity{AuthMethod: auth.AuthMethodMTLS}, false},
+		{"service claims", &auth.Identity{Claims: &auth.PrismClaims{TokenUse: auth.TokenUseService}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUserIdentity(tt.identity); got != tt.want {
+				t.Errorf("isUserIdentity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingExecer mencatat statement yang dijalankan applySettings.
+type recordingExecer struct {
+	sql  string
+	args []interface{}
+	err  error
+}
+
+func (e *recordingExecer) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	e.sql = sql
+	e.args = args
+	return pgconn.CommandTag{}, e.err
+}
+
+func TestApplySettings(t *testing.T) {
+	conn := &recordingExecer{}
+	settings := []setting{{SettingTenantID, testTenantID}, {SettingUserID, "'; RESET ALL; --"}}
+	if err := applySettings(context.Background(), conn, settings, true); err != nil {
+		t.Fatalf("applySettings: %v", err)
+	}
+	// Nilai hanya dikirim sebagai bind parameter, tidak pernah disisipkan ke SQL.
+	if want := "SELECT set_config($2, $3, $1), set_config($4, $5, $1)"; conn.sql != want {
+		t.Errorf("sql = %q, want %q", conn.sql, want)
+	}
+	if want := []interface{}{true, SettingTenantID, testTenantID, SettingUserID, "'; RESET ALL; --"}; !reflect.DeepEqual(conn.args, want) {
+		t.Errorf("args = %v, want %v", conn.args, want)
+	}
+
+	if err := applySettings(context.Background(), conn, withBlanks(nil), false); err != nil {
+		t.Fatalf("applySettings reset: %v", err)
+	}
+	if len(conn.args) != 1+2*len(settingNames) || conn.args[0] != false {
+		t.Errorf("reset args = %v, want session-level blanks for every setting", conn.args)
+	}
+
+	failing := &recordingExecer{err: errors.New("conn busy")}
+	if err := applySettings(context.Background(), failing, settings, false); err == nil {
+		t.Error("applySettings ignored the Exec error")
+	}
+}
@@ -4,8 +4,6 @@ package db
 import (
 	"context"
 	"fmt"
-	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
-	"github.com/google/uuid" // <-- Tambahkan import ini
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,55 +18,60 @@ type DBTX interface {
 
 // TenantDB adalah wrapper di sekitar pgxpool yang mengelola logika RLS.
 type TenantDB struct {
-	pool *pgxpool.Pool
+	pool  *pgxpool.Pool
+	retry retryPolicy
+	// resetByHook true jika pool dibuat oleh NewTenantDBWithConfig, sehingga reset dilakukan hook AfterRelease.
+	resetByHook bool
 }
 
-func NewTenantDB(pool *pgxpool.Pool) *TenantDB {
-	return &TenantDB{pool: pool}
+// Option mengonfigurasi TenantDB.
+type Option func(*TenantDB)
+
+func NewTenantDB(pool *pgxpool.Pool, opts ...Option) *TenantDB {
+	d := &TenantDB{pool: pool, retry: defaultRetryPolicy()}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
-// BeginTx memulai transaksi dan secara otomatis mengatur session variable untuk RLS.
+// BeginTx memulai transaksi dan secara otomatis mengatur session variable untuk RLS
+// (app.tenant_id, serta app.user_id, app.role, app.actor_id, dan app.request_id jika tersedia).
+// Pemanggil wajib melakukan Commit atau Rollback; lihat WithTx untuk versi yang mengurusnya.
 func (d *TenantDB) BeginTx(ctx context.Context) (pgx.Tx, error) {
-	// 1. Ekstrak tenantID dari context yang datang dari middleware JWT.
-	tenantIDStr, err := auth.GetTenantIDFromContext(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("gagal memulai transaksi tenant-aware: %w", err)
-	}
+	return d.beginTx(ctx, pgx.TxOptions{})
+}
 
-	// 2. [KRITIKAL] Validasi bahwa tenantID adalah UUID yang valid.
-	// Ini adalah langkah keamanan untuk mencegah SQL Injection.
-	// Jika parsing gagal, berarti string tersebut bukan UUID dan request harus ditolak.
-	_, err = uuid.Parse(tenantIDStr)
+func (d *TenantDB) beginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	// 1. Kumpulkan session variable RLS (tenant wajib, user/role/request ID jika ada) dari context.
+	settings, err := sessionSettings(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("invalid tenant ID format provided in context: %w", err)
+		return nil, err
 	}
 
-	// 3. Mulai transaksi database biasa.
-	tx, err := d.pool.Begin(ctx)
+	// 2. Mulai transaksi database biasa.
+	tx, err := d.pool.BeginTx(ctx, txOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	// 4. Bangun query SET LOCAL menggunakan Sprintf.
-	// Ini aman karena kita sudah memvalidasi tenantIDStr sebagai UUID.
-	// Perhatikan tanda kutip tunggal di sekitar '%s'.
-	setRLSQuery := fmt.Sprintf("SET LOCAL app.tenant_id = '%s'", tenantIDStr)
-
-	// 5. Jalankan query yang sudah diformat TANPA placeholder.
-	_, err = tx.Exec(ctx, setRLSQuery)
-	if err != nil {
+	// 3. Terapkan session variable dengan set_config(..., true) dan bind parameter, sehingga
+	// nilainya hanya berlaku di transaksi ini dan tidak pernah disisipkan ke teks SQL. Variable
+	// yang tidak diketahui dikosongkan agar nilai level sesi yang tertinggal di koneksi tidak terbawa.
+	if err := applySettings(ctx, tx, withBlanks(settings), true); err != nil {
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			return nil, fmt.Errorf("gagal rollback setelah error RLS context: %w (original error: %v)", rbErr, err)
 		}
 		return nil, fmt.Errorf("gagal mengatur RLS context: %w", err)
 	}
 
-	// 6. Kembalikan transaksi yang sekarang sudah "tenant-aware".
+	// 4. Kembalikan transaksi yang sekarang sudah "tenant-aware".
 	return tx, nil
 }
 
 // GetPool mengembalikan pool koneksi mentah. Hati-hati menggunakannya,
-// karena tidak akan secara otomatis mengatur RLS.
+// karena tidak akan secara otomatis mengatur RLS. Untuk query tunggal tanpa transaksi,
+// gunakan TenantDB langsung sebagai DBTX (Exec, Query, QueryRow).
 func (d *TenantDB) GetPool() *pgxpool.Pool {
     return d.pool
 }
\ No newline at end of file
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE yang menandakan transaksi aman untuk diulang dari awal.
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// retryPolicy mengatur pengulangan transaksi yang gagal karena konflik serialisasi atau deadlock.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{maxAttempts: 3, initialBackoff: 20 * time.Millisecond, maxBackoff: 500 * time.Millisecond}
+}
+
+// WithTxRetry mengatur jumlah percobaan WithTx (termasuk percobaan pertama) dan backoff
+// eksponensial di antaranya. maxAttempts 1 mematikan retry. Default 3 percobaan, 20ms-500ms.
+func WithTxRetry(maxAttempts int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(d *TenantDB) {
+		d.retry = retryPolicy{maxAttempts: maxAttempts, initialBackoff: initialBackoff, maxBackoff: maxBackoff}
+	}
+}
+
+// backoff menghitung jeda sebelum percobaan ke-attempt (dimulai dari 1) dengan jitter.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialBackoff << (attempt - 1)
+	if d <= 0 || (p.maxBackoff > 0 && d > p.maxBackoff) {
+		d = p.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// WithTx menjalankan fn di dalam transaksi tenant-aware (lihat BeginTx). Transaksi di-commit
+// jika fn mengembalikan nil, dan di-rollback jika fn mengembalikan error atau panic (panic
+// diteruskan setelah rollback).
+//
+// Jika transaksi gagal karena konflik serialisasi (SQLSTATE 40001) atau deadlock (40P01),
+// seluruh fn diulang dengan backoff sesuai WithTxRetry. Karena itu fn harus bisa diulang:
+// jangan melakukan efek samping di luar database (misal mengirim email) di dalamnya.
+func (d *TenantDB) WithTx(ctx context.Context, fn func(tx DBTX) error) error {
+	return d.WithTxOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+// WithReadOnlyTx sama seperti WithTx dengan transaksi READ ONLY.
+func (d *TenantDB) WithReadOnlyTx(ctx context.Context, fn func(tx DBTX) error) error {
+	return d.WithTxOptions(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, fn)
+}
+
+// WithIsolationTx sama seperti WithTx dengan isolation level tertentu, misalnya pgx.Serializable
+// untuk operasi saldo atau penomoran dokumen.
+func (d *TenantDB) WithIsolationTx(ctx context.Context, level pgx.TxIsoLevel, fn func(tx DBTX) error) error {
+	return d.WithTxOptions(ctx, pgx.TxOptions{IsoLevel: level}, fn)
+}
+
+// WithTxOptions adalah bentuk umum WithTx dengan pgx.TxOptions bebas.
+func (d *TenantDB) WithTxOptions(ctx context.Context, txOptions pgx.TxOptions, fn func(tx DBTX) error) error {
+	return d.retry.run(ctx, func() error {
+		return d.runTx(ctx, txOptions, fn)
+	})
+}
+
+// run menjalankan attempt dan mengulanginya dengan backoff selama error-nya retryable.
+func (p retryPolicy) run(ctx context.Context, attempt func() error) error {
+	attempts := p.maxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("transaksi dibatalkan saat menunggu retry: %w (error terakhir: %v)", ctx.Err(), err)
+			case <-time.After(p.backoff(i)):
+			}
+		}
+		err = attempt()
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("transaksi gagal setelah %d percobaan: %w", attempts, err)
+}
+
+// runTx menjalankan satu percobaan transaksi.
+func (d *TenantDB) runTx(ctx context.Context, txOptions pgx.TxOptions, fn func(tx DBTX) error) (err error) {
+	tx, err := d.beginTx(ctx, txOptions)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			// Rollback memakai context terpisah agar tetap berjalan meski ctx sudah dibatalkan.
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			return fmt.Errorf("%w (rollback juga gagal: %v)", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// isRetryableTxError memeriksa apakah err berasal dari konflik serialisasi atau deadlock.
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
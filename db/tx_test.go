@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"wrapped serialization failure", fmt.Errorf("commit: %w", &pgconn.PgError{Code: "40001"}), true},
+		// Bentuk error runTx saat rollback juga gagal.
+		{"wrapped with rollback failure", fmt.Errorf("%w (rollback juga gagal: %v)", &pgconn.PgError{Code: "40001"}, errors.New("conn closed")), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"other class 40", &pgconn.PgError{Code: "40002"}, false},
+		{"plain error", errors.New("40001"), false},
+		{"no rows", pgx.ErrNoRows, false},
+		{"context canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableTxError(tt.err); got != tt.want {
+				t.Errorf("isRetryableTxError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{maxAttempts: 5, initialBackoff: 20 * time.Millisecond, maxBackoff: 100 * time.Millisecond}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 10 * time.Millisecond, 20 * time.Millisecond},
+		{2, 20 * time.Millisecond, 40 * time.Millisecond},
+		{3, 40 * time.Millisecond, 80 * time.Millisecond},
+		{4, 50 * time.Millisecond, 100 * time.Millisecond},
+		// Shift yang melimpah tetap dibatasi maxBackoff.
+		{70, 50 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if d := p.backoff(tt.attempt); d < tt.min || d > tt.max {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+
+	if d := (retryPolicy{}).backoff(1); d != 0 {
+		t.Errorf("zero policy backoff = %v, want 0", d)
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	serialization := &pgconn.PgError{Code: "40001"}
+	deadlock := &pgconn.PgError{Code: "40P01"}
+	unique := &pgconn.PgError{Code: "23505"}
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		errs         []error // error per percobaan; percobaan setelahnya berhasil
+		wantAttempts int
+		wantErr      error
+	}{
+		{"success", 3, nil, 1, nil},
+		{"retry serialization failure", 3, []error{serialization}, 2, nil},
+		{"retry deadlock then serialization failure", 3, []error{deadlock, serialization}, 3, nil},
+		{"gives up after max attempts", 3, []error{serialization, serialization, serialization}, 3, serialization},
+		{"no retry for other errors", 3, []error{unique}, 1, unique},
+		{"max attempts one disables retry", 1, []error{serialization}, 1, serialization},
+		{"zero max attempts still runs once", 0, []error{serialization}, 1, serialization},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := retryPolicy{maxAttempts: tt.maxAttempts, initialBackoff: time.Microsecond, maxBackoff: time.Millisecond}
+			attempts := 0
+			err := p.run(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRunCanceledDuringBackoff(t *testing.T) {
+	p := retryPolicy{maxAttempts: 3, initialBackoff: time.Hour, maxBackoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := p.run(ctx, func() error {
+		attempts++
+		cancel()
+		return &pgconn.PgError{Code: "40001"}
+	})
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,46 @@
+package ginutil
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used to propagate the request ID between services.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns a child context carrying the request ID, e.g. for background jobs
+// or gRPC handlers that receive the ID from metadata.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored by RequestID or WithRequestID.
+// For *gin.Context the ID is read from c.Request.Context().
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	if ginCtx, ok := ctx.(*gin.Context); ok {
+		if ginCtx.Request == nil {
+			return "", false
+		}
+		ctx = ginCtx.Request.Context()
+	}
+	requestID, ok := ctx.Value(requestIDContextKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+// RequestID reuses the incoming X-Request-ID header or generates a new UUID, echoes it in
+// the response and stores it in the request context so logs and db.TenantDB can pick it up.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}